@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/gkwa/dailyare/core"
+)
+
+var applyCmd = &cobra.Command{
+	Use:   "apply PLAN",
+	Short: "Clear the notifications listed in a plan file",
+	Long:  `Execute a plan previously written by the plan command without re-evaluating the notifications in it.`,
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		logger := LoggerFrom(cmd.Context())
+
+		plan, err := core.ReadPlan(args[0])
+		if err != nil {
+			return err
+		}
+
+		cache, err := newCacheService(logger)
+		if err != nil {
+			return err
+		}
+
+		service, err := newNotificationService(logger, cache)
+		if err != nil {
+			return err
+		}
+
+		lock, err := lockRun(logger)
+		if err != nil {
+			return err
+		}
+		defer lock.Release()
+		defer cache.Close()
+
+		return service.Apply(logger, plan)
+	},
+}
+
+func init() {
+	// Only the flags configuring the service matter, but apply shares them
+	// all so that it clears notifications the same way a sweep would.
+	addSweepFlags(applyCmd.Flags())
+	rootCmd.AddCommand(applyCmd)
+}
@@ -0,0 +1,29 @@
+package cmd
+
+import (
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gkwa/dailyare/core"
+)
+
+func TestApplyWithoutConfig(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("GH_CONFIG_DIR", home)
+	t.Setenv("GH_TOKEN", "test-token")
+
+	planPath := filepath.Join(home, "plan.json")
+	if err := core.WritePlan(planPath, &core.Plan{CreatedAt: time.Now(), Since: "7d"}); err != nil {
+		t.Fatalf("Failed to write plan: %v", err)
+	}
+
+	rootCmd.SetOut(io.Discard)
+	defer rootCmd.SetOut(nil)
+	rootCmd.SetArgs([]string{"apply", planPath})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("Expected apply to use the flag defaults without a config file, got %v", err)
+	}
+}
@@ -0,0 +1,323 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/gkwa/dailyare/core"
+)
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Inspect and maintain the local cache",
+	Long: `Show what the cache holds and how often it is hit, and drop, prune, clear, export or import entries without editing the cache file by hand.
+
+Entries are of the kinds ` + strings.Join(core.CacheKinds, ", ") + `. PR, issue and response entries are keyed by API URL, thread entries by thread ID.`,
+}
+
+var cacheStatsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show entry counts and hit rates",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return withCache(cmd, false, func(cache core.CacheService, format string) error {
+			return printCacheStats(cmd.OutOrStdout(), cache.Stats(), format)
+		})
+	},
+}
+
+var cacheListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List cache entries",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return withCache(cmd, false, func(cache core.CacheService, format string) error {
+			entries, err := cache.Entries(viper.GetString("kind"))
+			if err != nil {
+				return err
+			}
+			return printCacheEntries(cmd.OutOrStdout(), entries, format, false)
+		})
+	},
+}
+
+var cacheGetCmd = &cobra.Command{
+	Use:   "get KEY",
+	Short: "Show the entries stored under a key",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return withCache(cmd, false, func(cache core.CacheService, format string) error {
+			entries, err := cache.Entries(viper.GetString("kind"))
+			if err != nil {
+				return err
+			}
+			var found []core.CacheEntry
+			for _, entry := range entries {
+				if entry.Key == args[0] {
+					found = append(found, entry)
+				}
+			}
+			if len(found) == 0 {
+				return fmt.Errorf("no cache entry for %q", args[0])
+			}
+			return printCacheEntries(cmd.OutOrStdout(), found, format, true)
+		})
+	},
+}
+
+var cacheForgetCmd = &cobra.Command{
+	Use:   "forget KEY",
+	Short: "Drop the entries stored under a key",
+	Long:  `Drop the entries stored under a key, for example a pull request URL, so that the next run looks it up again.`,
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return withCache(cmd, true, func(cache core.CacheService, format string) error {
+			n, err := cache.Forget(viper.GetString("kind"), args[0])
+			if err != nil {
+				return err
+			}
+			if n == 0 {
+				return fmt.Errorf("no cache entry for %q", args[0])
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Forgot %d entry(s) for %s\n", n, args[0])
+			return nil
+		})
+	},
+}
+
+var cachePruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Evict entries now according to --cache-max-age and --cache-max-entries",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return withCache(cmd, true, func(cache core.CacheService, format string) error {
+			evicted, err := cache.Prune()
+			if err != nil {
+				return err
+			}
+			return printEvicted(cmd.OutOrStdout(), evicted, format)
+		})
+	},
+}
+
+var cacheClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Remove every cache entry",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return withCache(cmd, true, func(cache core.CacheService, format string) error {
+			if err := cache.Clear(); err != nil {
+				return err
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), "Cleared the cache")
+			return nil
+		})
+	},
+}
+
+var cacheExportCmd = &cobra.Command{
+	Use:   "export [FILE]",
+	Short: "Write the cache as JSON to a file, or to standard output",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return withCache(cmd, false, func(cache core.CacheService, format string) error {
+			if len(args) == 0 || args[0] == "-" {
+				return cache.Export(cmd.OutOrStdout())
+			}
+			f, err := os.Create(args[0])
+			if err != nil {
+				return err
+			}
+			if err := cache.Export(f); err != nil {
+				f.Close()
+				return err
+			}
+			return f.Close()
+		})
+	},
+}
+
+var cacheImportCmd = &cobra.Command{
+	Use:   "import FILE",
+	Short: "Replace the cache with one written by export, or - to read standard input",
+	Long:  `Replace the cache with one written by export. Caches exported by older versions of dailyare are migrated on import.`,
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return withCache(cmd, true, func(cache core.CacheService, format string) error {
+			if args[0] == "-" {
+				return cache.Import(cmd.InOrStdin())
+			}
+			f, err := os.Open(args[0])
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			return cache.Import(f)
+		})
+	},
+}
+
+var cacheHistoryCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Show the most recent runs and what they cleared",
+	Long:  `Show the most recent runs and what they cleared. Only the sqlite cache backend keeps a run history.`,
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return withCache(cmd, false, func(cache core.CacheService, format string) error {
+			history, ok := cache.(core.RunHistory)
+			if !ok {
+				return fmt.Errorf("the %s cache backend keeps no run history; set cache-backend: %s", viper.GetString("cache-backend"), cacheBackendSQLite)
+			}
+			runs, err := history.Runs(viper.GetInt("limit"))
+			if err != nil {
+				return err
+			}
+			return printRuns(cmd.OutOrStdout(), runs, format)
+		})
+	},
+}
+
+// withCache loads the cache and runs fn on it, saving it afterwards if fn
+// modifies it. Every command takes the run lock, as even loading can write:
+// it upgrades an old cache in place and imports cache.json into a new
+// database.
+func withCache(cmd *cobra.Command, modify bool, fn func(cache core.CacheService, format string) error) error {
+	logger := LoggerFrom(cmd.Context())
+
+	format := viper.GetString("output")
+	if err := validateOutput(format); err != nil {
+		return err
+	}
+
+	cache, err := newCacheService(logger)
+	if err != nil {
+		return err
+	}
+	cmd.SilenceUsage = true
+
+	lock, err := lockRun(logger)
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+	defer cache.Close()
+
+	loaded, err := cache.Load()
+	if err != nil {
+		return err
+	}
+	if err := fn(cache, format); err != nil {
+		return err
+	}
+	if !modify {
+		return nil
+	}
+	return cache.Save(loaded)
+}
+
+func printCacheStats(w io.Writer, stats core.CacheStats, format string) error {
+	if format == outputJSON {
+		return printJSON(w, stats)
+	}
+
+	fmt.Fprintf(w, "Location:    %s\n", stats.Location)
+	fmt.Fprintf(w, "Version:     %d\n", stats.Version)
+	fmt.Fprintf(w, "Last sweep:  %s\n", formatTime(stats.LastSweep))
+	fmt.Fprintf(w, "Max window:  %s\n\n", stats.MaxWindow)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "KIND\tENTRIES\tHITS\tMISSES\tHIT RATE")
+	for _, kind := range core.CacheKinds {
+		k := stats.Kinds[kind]
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%.1f%%\n", kind, k.Entries, k.Hits, k.Misses, 100*k.HitRate)
+	}
+	return tw.Flush()
+}
+
+// printCacheEntries writes entries as a table, with their values if
+// withValue is set, or as JSON.
+func printCacheEntries(w io.Writer, entries []core.CacheEntry, format string, withValue bool) error {
+	if format == outputJSON {
+		if entries == nil {
+			entries = []core.CacheEntry{}
+		}
+		return printJSON(w, entries)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	header := "KIND\tKEY\tUSED"
+	if withValue {
+		header += "\tVALUE"
+	}
+	fmt.Fprintln(tw, header)
+	for _, entry := range entries {
+		fmt.Fprintf(tw, "%s\t%s\t%s", entry.Kind, entry.Key, formatTime(entry.UsedAt))
+		if withValue {
+			fmt.Fprintf(tw, "\t%s", entry.Value)
+		}
+		fmt.Fprintln(tw)
+	}
+	return tw.Flush()
+}
+
+func printEvicted(w io.Writer, evicted map[string]int, format string) error {
+	if format == outputJSON {
+		return printJSON(w, evicted)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "KIND\tEVICTED")
+	for _, kind := range core.CacheKinds {
+		fmt.Fprintf(tw, "%s\t%d\n", kind, evicted[kind])
+	}
+	return tw.Flush()
+}
+
+func printRuns(w io.Writer, runs []core.RunRecord, format string) error {
+	if format == outputJSON {
+		if runs == nil {
+			runs = []core.RunRecord{}
+		}
+		return printJSON(w, runs)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "STARTED\tDURATION\tSINCE\tFETCHED\tFILTERED\tSKIPPED\tCHECKED\tCLEARED\tFAILED\tERROR")
+	for _, run := range runs {
+		c := run.Summary.Counts
+		runErr := run.Error
+		if runErr == "" {
+			runErr = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%d\t%d\t%d\t%d\t%d\t%s\n",
+			formatTime(run.StartedAt), run.FinishedAt.Sub(run.StartedAt).Round(time.Second), run.Since,
+			c.Fetched, c.Filtered, c.Skipped, c.Checked, c.Cleared, c.Failed, runErr)
+	}
+	return tw.Flush()
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format(time.RFC3339)
+}
+
+func init() {
+	cacheCmd.PersistentFlags().StringP("output", "o", outputTable, "Format of the output: table or json")
+	addCacheFlags(cacheCmd.PersistentFlags())
+	addWaitFlag(cacheCmd.PersistentFlags())
+	for _, c := range []*cobra.Command{cacheListCmd, cacheGetCmd, cacheForgetCmd} {
+		c.Flags().String("kind", "", "Only consider entries of this kind: "+strings.Join(core.CacheKinds, ", "))
+	}
+	cacheHistoryCmd.Flags().Int("limit", 20, "Most runs to show")
+	cacheCmd.AddCommand(cacheStatsCmd, cacheListCmd, cacheGetCmd, cacheForgetCmd, cachePruneCmd, cacheClearCmd, cacheExportCmd, cacheImportCmd, cacheHistoryCmd)
+	rootCmd.AddCommand(cacheCmd)
+}
@@ -0,0 +1,144 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gkwa/dailyare/core"
+)
+
+func TestCacheStatsTakesLock(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	// A cache from before versioning, which loading would upgrade in place.
+	dir := core.CacheDir(home)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatalf("Failed to create cache dir: %v", err)
+	}
+	legacy := []byte(`{"pr_status":{"pr1":true}}`)
+	if err := os.WriteFile(filepath.Join(dir, "cache.json"), legacy, 0o644); err != nil {
+		t.Fatalf("Failed to write cache: %v", err)
+	}
+
+	lock, err := core.AcquireRunLock(dir, 0)
+	if err != nil {
+		t.Fatalf("Failed to take the lock: %v", err)
+	}
+	defer lock.Release()
+
+	rootCmd.SetOut(io.Discard)
+	rootCmd.SetErr(io.Discard)
+	defer rootCmd.SetOut(nil)
+	defer rootCmd.SetErr(nil)
+	rootCmd.SetArgs([]string{"cache", "stats"})
+	if err := rootCmd.Execute(); !errors.Is(err, core.ErrLocked) {
+		t.Fatalf("Expected ErrLocked while a sweep holds the lock, got %v", err)
+	}
+
+	if data, _ := os.ReadFile(filepath.Join(dir, "cache.json")); !bytes.Equal(data, legacy) {
+		t.Errorf("Expected the cache to be left alone, got %s", data)
+	}
+}
+
+func TestPrintCacheStats(t *testing.T) {
+	stats := core.CacheStats{
+		Location: "/home/me/.dailyare/cache.json",
+		Version:  4,
+		Kinds: map[string]core.KindStats{
+			core.CacheKindPR: {Entries: 2, Lookups: core.Lookups{Hits: 3, Misses: 1}, HitRate: 0.75},
+		},
+	}
+
+	var table bytes.Buffer
+	if err := printCacheStats(&table, stats, outputTable); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.Contains(table.String(), "75.0%") {
+		t.Errorf("Expected the PR hit rate in the table, got:\n%s", table.String())
+	}
+
+	var out bytes.Buffer
+	if err := printCacheStats(&out, stats, outputJSON); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var decoded core.CacheStats
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil {
+		t.Fatalf("Invalid JSON stats: %v", err)
+	}
+	if decoded.Kinds[core.CacheKindPR].Hits != 3 {
+		t.Errorf("Unexpected JSON stats: %s", out.String())
+	}
+}
+
+func TestPrintCacheEntries(t *testing.T) {
+	entries := []core.CacheEntry{
+		{Kind: core.CacheKindPR, Key: "pr1", UsedAt: time.Now(), Value: json.RawMessage(`{"state":"merged"}`)},
+		{Kind: core.CacheKindUnsubscribed, Key: "1", Value: json.RawMessage(`true`)},
+	}
+
+	var list bytes.Buffer
+	if err := printCacheEntries(&list, entries, outputTable, false); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if strings.Contains(list.String(), "merged") {
+		t.Errorf("Expected list not to show values, got:\n%s", list.String())
+	}
+
+	var get bytes.Buffer
+	if err := printCacheEntries(&get, entries, outputTable, true); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.Contains(get.String(), `{"state":"merged"}`) {
+		t.Errorf("Expected get to show values, got:\n%s", get.String())
+	}
+
+	var empty bytes.Buffer
+	if err := printCacheEntries(&empty, nil, outputJSON, false); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if strings.TrimSpace(empty.String()) != "[]" {
+		t.Errorf("Expected an empty JSON list, got %q", empty.String())
+	}
+}
+
+func TestPrintRuns(t *testing.T) {
+	start := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	runs := []core.RunRecord{{
+		StartedAt:  start,
+		FinishedAt: start.Add(90 * time.Second),
+		Since:      "1d",
+		Summary:    core.Summary{Counts: core.Counts{Fetched: 14, Cleared: 5}},
+	}, {
+		StartedAt:  start.Add(-time.Hour),
+		FinishedAt: start.Add(-time.Hour),
+		Since:      "1d",
+		Error:      "API rate limit exceeded",
+	}}
+
+	var table bytes.Buffer
+	if err := printRuns(&table, runs, outputTable); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.Contains(table.String(), "1m30s") {
+		t.Errorf("Expected the run duration in the table, got:\n%s", table.String())
+	}
+	if !strings.Contains(table.String(), "API rate limit exceeded") {
+		t.Errorf("Expected the error of the failed run in the table, got:\n%s", table.String())
+	}
+
+	var empty bytes.Buffer
+	if err := printRuns(&empty, nil, outputJSON); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if strings.TrimSpace(empty.String()) != "[]" {
+		t.Errorf("Expected an empty JSON list, got %q", empty.String())
+	}
+}
@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/gkwa/dailyare/core"
+)
+
+var planCmd = &cobra.Command{
+	Use:   "plan",
+	Short: "Write the notifications that would be cleared to a plan file",
+	Long:  `Evaluate notifications exactly like a normal run, but instead of clearing them write the decisions to a JSON plan file that can be reviewed and passed to apply.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		logger := LoggerFrom(cmd.Context())
+
+		cache, err := newCacheService(logger)
+		if err != nil {
+			return err
+		}
+
+		service, err := newNotificationService(logger, cache)
+		if err != nil {
+			return err
+		}
+
+		// Errors from here on are about the sweep, not the command line.
+		cmd.SilenceUsage = true
+
+		lock, err := lockRun(logger)
+		if err != nil {
+			return err
+		}
+		defer lock.Release()
+		defer cache.Close()
+
+		plan, err := service.Plan(logger, viper.GetString("since"), viper.GetBool("no-cache"))
+		if err != nil {
+			return err
+		}
+
+		planFile := viper.GetString("out")
+		if planFile == "-" {
+			printPlan(cmd.OutOrStdout(), plan)
+			return plan.Incomplete()
+		}
+
+		if err := core.WritePlan(planFile, plan); err != nil {
+			return err
+		}
+		logger.Info("Wrote plan", "path", planFile, "decisions", len(plan.Decisions))
+		return plan.Incomplete()
+	},
+}
+
+func init() {
+	addSweepFlags(planCmd.Flags())
+	planCmd.Flags().StringP("out", "o", "dailyare-plan.json", "Path of the plan file to write, or - to print it")
+	rootCmd.AddCommand(planCmd)
+}
@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/gkwa/dailyare/core"
+)
+
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+func validateOutput(format string) error {
+	if format != outputTable && format != outputJSON {
+		return fmt.Errorf("invalid output %q: must be %s or %s", format, outputTable, outputJSON)
+	}
+	return nil
+}
+
+// printSummary writes the report of a sweep as a table with a row per
+// repository, or as JSON.
+func printSummary(w io.Writer, summary *core.Summary, format string) error {
+	if format == outputJSON {
+		return printJSON(w, summary)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "REPOSITORY\tFETCHED\tFILTERED\tSKIPPED\tCHECKED\tCLEARED\tFAILED")
+	row := func(name string, c core.Counts) {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t%d\t%d\n", name, c.Fetched, c.Filtered, c.Skipped, c.Checked, c.Cleared, c.Failed)
+	}
+	for _, repo := range summary.RepositoryNames() {
+		row(repo, summary.Repositories[repo])
+	}
+	row("TOTAL", summary.Counts)
+	return tw.Flush()
+}
+
+func printJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
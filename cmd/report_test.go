@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/gkwa/dailyare/core"
+)
+
+func TestPrintSummary(t *testing.T) {
+	summary := &core.Summary{
+		Counts: core.Counts{Fetched: 3, Checked: 3, Cleared: 2, Failed: 1},
+		Repositories: map[string]core.Counts{
+			"org/b": {Fetched: 1, Checked: 1, Failed: 1},
+			"org/a": {Fetched: 2, Checked: 2, Cleared: 2},
+		},
+	}
+
+	var table bytes.Buffer
+	if err := printSummary(&table, summary, outputTable); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(table.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("Expected a header, 2 repositories and a total, got:\n%s", table.String())
+	}
+	if !strings.HasPrefix(lines[1], "org/a") || !strings.HasPrefix(lines[3], "TOTAL") {
+		t.Errorf("Expected sorted repositories followed by the total, got:\n%s", table.String())
+	}
+
+	var out bytes.Buffer
+	if err := printSummary(&out, summary, outputJSON); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var decoded core.Summary
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil {
+		t.Fatalf("Invalid JSON report: %v", err)
+	}
+	if decoded.Cleared != 2 || decoded.Repositories["org/b"].Failed != 1 {
+		t.Errorf("Unexpected JSON report: %s", out.String())
+	}
+}
+
+func TestValidateOutput(t *testing.T) {
+	if err := validateOutput("yaml"); err == nil {
+		t.Error("Expected error for an unknown format, got nil")
+	}
+}
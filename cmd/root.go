@@ -2,10 +2,10 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 
-	"github.com/cli/go-gh/v2/pkg/api"
 	"github.com/go-logr/logr"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -19,8 +19,6 @@ var (
 	verbose   int
 	logFormat string
 	cliLogger logr.Logger
-	since     string
-	noCache   bool
 )
 
 var rootCmd = &cobra.Command{
@@ -32,36 +30,79 @@ var rootCmd = &cobra.Command{
 			cliLogger = logger.NewConsoleLogger(verbose, logFormat == "json")
 		}
 
+		// Let every flag of the running command also be set from the config file.
+		cobra.CheckErr(viper.BindPFlags(cmd.Flags()))
+
 		ctx := logr.NewContext(context.Background(), cliLogger)
 		cmd.SetContext(ctx)
 	},
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		logger := LoggerFrom(cmd.Context())
 		logger.Info("Running command")
 
-		client, err := api.DefaultRESTClient()
+		output := viper.GetString("output")
+		if err := validateOutput(output); err != nil {
+			return err
+		}
+
+		cache, err := newCacheService(logger)
 		if err != nil {
-			logger.Error(err, "Failed to create REST client")
-			return
+			return fmt.Errorf("failed to create notification service: %w", err)
 		}
 
-		notificationRepo := core.NewGithubRepository(client)
-		prService := core.NewGithubPRService(client)
-		cacheService := core.NewFileCacheService(viper.GetString("home"))
-		service := core.NewNotificationService(notificationRepo, prService, cacheService)
+		service, err := newNotificationService(logger, cache)
+		if err != nil {
+			return fmt.Errorf("failed to create notification service: %w", err)
+		}
+
+		// Errors from here on are about the sweep, not the command line.
+		cmd.SilenceUsage = true
 
-		err = service.FetchNotifications(logger, since, noCache)
+		lock, err := lockRun(logger)
 		if err != nil {
-			logger.Error(err, "Failed to fetch notifications")
+			return err
+		}
+		defer lock.Release()
+		defer cache.Close()
+
+		if viper.GetBool("dry-run") {
+			plan, err := service.Plan(logger, viper.GetString("since"), viper.GetBool("no-cache"))
+			if err != nil {
+				return fmt.Errorf("failed to fetch notifications: %w", err)
+			}
+			printPlan(cmd.OutOrStdout(), plan)
+			return plan.Incomplete()
 		}
+
+		summary, err := service.FetchNotifications(logger, viper.GetString("since"), viper.GetBool("no-cache"))
+		if summary == nil {
+			return fmt.Errorf("failed to fetch notifications: %w", err)
+		}
+		if printErr := printSummary(cmd.OutOrStdout(), summary, output); printErr != nil {
+			return printErr
+		}
+		return err
 	},
 }
 
 func Execute() {
-	err := rootCmd.Execute()
-	if err != nil {
-		os.Exit(1)
+	if code := exitCode(rootCmd.Execute()); code != 0 {
+		os.Exit(code)
+	}
+}
+
+// exitCode is 1 when a run fails outright, for example because the
+// notifications could not be fetched, and 2 when it completes but some
+// notifications could not be evaluated or cleared, or the fetch stopped at
+// the page limit.
+func exitCode(err error) int {
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, core.ErrClearFailed), errors.Is(err, core.ErrIncomplete):
+		return 2
 	}
+	return 1
 }
 
 func init() {
@@ -70,8 +111,9 @@ func init() {
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.dailyare.yaml)")
 	rootCmd.PersistentFlags().CountVarP(&verbose, "verbose", "v", "increase verbosity")
 	rootCmd.PersistentFlags().StringVar(&logFormat, "log-format", "", "json or text (default is text)")
-	rootCmd.Flags().StringVar(&since, "since", "7d", "Filter notifications by time (default: 7d)")
-	rootCmd.Flags().BoolVar(&noCache, "no-cache", false, "Bypass the cache and fetch fresh data")
+	addSweepFlags(rootCmd.Flags())
+	rootCmd.Flags().Bool("dry-run", false, "Report which notifications would be cleared without clearing them")
+	rootCmd.Flags().StringP("output", "o", outputTable, "Format of the run report: table or json")
 
 	if err := viper.BindPFlag("verbose", rootCmd.PersistentFlags().Lookup("verbose")); err != nil {
 		fmt.Printf("Error binding verbose flag: %v\n", err)
@@ -2,11 +2,17 @@ package cmd
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
+	"github.com/spf13/viper"
+
+	"github.com/gkwa/dailyare/core"
 	"github.com/gkwa/dailyare/internal/logger"
 )
 
@@ -76,3 +82,49 @@ func TestJSONLogger(t *testing.T) {
 
 	t.Logf("Command output: %s", output)
 }
+
+func TestFlagsFromConfigFile(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("GH_CONFIG_DIR", home)
+	t.Setenv("GH_TOKEN", "test-token")
+
+	config := filepath.Join(home, ".dailyare.yaml")
+	if err := os.WriteFile(config, []byte("since: 2024-13-45\n"), 0o644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	t.Cleanup(func() {
+		// viper keeps the last config read, so later tests must not see this one.
+		viper.SetConfigType("yaml")
+		viper.ReadConfig(strings.NewReader(""))
+	})
+
+	rootCmd.SetOut(io.Discard)
+	rootCmd.SetErr(io.Discard)
+	defer rootCmd.SetOut(nil)
+	defer rootCmd.SetErr(nil)
+	rootCmd.SetArgs([]string{"plan", "--out", "-"})
+	err := rootCmd.Execute()
+	if err == nil || !strings.Contains(err.Error(), "2024-13-45") {
+		t.Errorf("Expected since from the config file to be used, got %v", err)
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{nil, 0},
+		{errors.New("failed to fetch notifications"), 1},
+		{fmt.Errorf("%w: 1 of 2 failed", core.ErrClearFailed), 2},
+		{fmt.Errorf("%w: stopped at the page limit", core.ErrIncomplete), 2},
+		{errors.Join(fmt.Errorf("%w: 1 could not be evaluated", core.ErrIncomplete), core.ErrClearFailed), 2},
+	}
+
+	for _, tt := range tests {
+		if got := exitCode(tt.err); got != tt.want {
+			t.Errorf("exitCode(%v) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/cli/go-gh/v2/pkg/api"
+	"github.com/go-logr/logr"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+
+	"github.com/gkwa/dailyare/core"
+)
+
+// addSweepFlags registers the flags shared by every command that fetches
+// and evaluates notifications.
+func addSweepFlags(flags *pflag.FlagSet) {
+	flags.String("since", "7d", "Only fetch notifications updated since this long ago (e.g. 36h, 1d12h, 2w, 1mo), this date (YYYY-MM-DD or RFC 3339), or auto for since the last complete sweep")
+	flags.String("before", "", "Only fetch notifications updated before this long ago or this date, to sweep a specific window")
+	flags.Bool("no-cache", false, "Bypass the cache and fetch fresh data")
+	flags.Int("per-page", 50, "Number of notifications to request per page (max 50)")
+	flags.Int("max-pages", 20, "Maximum number of notification pages to fetch")
+	flags.String("action", core.ActionDone, "How to clear notifications: done removes them from the inbox, read marks them as read")
+	flags.String("subscription", core.SubscriptionKeep, "What to do with the thread subscription after clearing: keep, unsubscribe or ignore")
+	flags.Bool("clear-closed-prs", false, "Also clear notifications for pull requests closed without merging (ignored when rules are configured)")
+	flags.Bool("clear-not-planned", false, "Also clear notifications for issues closed as not planned (ignored when rules are configured)")
+	flags.Bool("graphql", true, "Look up pull request statuses in batches through GraphQL, falling back to REST")
+	flags.Int("max-retries", 3, "Retries after a rate limit or, for safe requests, a server error")
+	flags.Duration("rate-limit-wait", 5*time.Minute, "Longest time to wait for a rate limit to reset before giving up")
+	flags.Bool("unread-only", false, "Only fetch unread notifications, skipping threads already read")
+	flags.Bool("participating", false, "Only fetch notifications for threads you participate in or are mentioned in")
+	flags.StringSlice("repo", nil, "Only process notifications from these repositories (globs such as myorg/* allowed, repeatable)")
+	flags.StringSlice("exclude-repo", nil, "Skip notifications from these repositories (globs allowed, repeatable)")
+	flags.StringSlice("org", nil, "Only process notifications from repositories owned by these users or organizations")
+	flags.StringSlice("exclude-org", nil, "Skip notifications from repositories owned by these users or organizations")
+	flags.StringSlice("reason", nil, "Only process notifications with these reasons, e.g. review_requested, mention, subscribed, ci_activity")
+	flags.StringSlice("exclude-reason", nil, "Skip notifications with these reasons")
+	flags.StringSlice("type", nil, "Only process notifications about these subject types, e.g. PullRequest, Issue, Release")
+	flags.StringSlice("exclude-type", nil, "Skip notifications about these subject types")
+	flags.Int("concurrency", 1, "Number of notifications to check and clear in parallel")
+	addWaitFlag(flags)
+	flags.Duration("status-ttl", time.Hour, "How long a cached status for an open PR or issue is trusted before re-checking")
+	addCacheFlags(flags)
+}
+
+const (
+	cacheBackendFile   = "file"
+	cacheBackendSQLite = "sqlite"
+)
+
+// addCacheFlags registers the flags configuring the cache for every command
+// that uses it.
+func addCacheFlags(flags *pflag.FlagSet) {
+	flags.String("cache-backend", cacheBackendFile, "Where the cache is kept: file (cache.json) or sqlite (cache.db, which also keeps a run history)")
+	flags.String("cache-max-age", "90d", "Evict cache entries unused for this long (e.g. 30d, 6mo), or 0 to keep them")
+	flags.Int("cache-max-entries", 10000, "Most entries kept for each kind of cached data, evicting the least recently used; 0 for no limit")
+}
+
+func newCacheService(logger logr.Logger) (core.CacheService, error) {
+	eviction := core.EvictionPolicy{MaxEntries: viper.GetInt("cache-max-entries")}
+	if maxAge := viper.GetString("cache-max-age"); maxAge != "" && maxAge != "0" {
+		var err error
+		if eviction.MaxAge, err = core.ParseDuration(maxAge); err != nil {
+			return nil, fmt.Errorf("invalid --cache-max-age: %w", err)
+		}
+	}
+	opts := []core.CacheOption{core.WithCacheLogger(logger), core.WithEviction(eviction)}
+
+	switch backend := viper.GetString("cache-backend"); backend {
+	case cacheBackendFile:
+		return core.NewFileCacheService(viper.GetString("home"), opts...), nil
+	case cacheBackendSQLite:
+		return core.NewSQLiteCacheService(viper.GetString("home"), opts...), nil
+	default:
+		return nil, fmt.Errorf("invalid --cache-backend %q: must be %s or %s", backend, cacheBackendFile, cacheBackendSQLite)
+	}
+}
+
+// addWaitFlag registers --wait for every command that takes the run lock.
+func addWaitFlag(flags *pflag.FlagSet) {
+	flags.Duration("wait", 0, "How long to wait for another dailyare run to finish before giving up")
+}
+
+// lockRun takes the run lock so that overlapping runs cannot clobber each
+// other's cache.
+func lockRun(logger logr.Logger) (*core.RunLock, error) {
+	wait := viper.GetDuration("wait")
+	if wait > 0 {
+		logger.V(1).Info("Waiting for the run lock", "wait", wait.String())
+	}
+	return core.AcquireRunLock(core.CacheDir(viper.GetString("home")), wait)
+}
+
+// newNotificationService returns a service keeping what it learns in
+// cacheService, which the caller closes once the service is done.
+func newNotificationService(logger logr.Logger, cacheService core.CacheService) (core.NotificationService, error) {
+	rules, err := loadRules()
+	if err != nil {
+		return nil, err
+	}
+
+	subscription := viper.GetString("subscription")
+	switch subscription {
+	case core.SubscriptionKeep, core.SubscriptionUnsubscribe, core.SubscriptionIgnore:
+	default:
+		return nil, fmt.Errorf("invalid subscription %q: must be %s, %s or %s",
+			subscription, core.SubscriptionKeep, core.SubscriptionUnsubscribe, core.SubscriptionIgnore)
+	}
+
+	filter := core.Filter{
+		Repositories:        viper.GetStringSlice("repo"),
+		ExcludeRepositories: viper.GetStringSlice("exclude-repo"),
+		Owners:              viper.GetStringSlice("org"),
+		ExcludeOwners:       viper.GetStringSlice("exclude-org"),
+		Reasons:             viper.GetStringSlice("reason"),
+		ExcludeReasons:      viper.GetStringSlice("exclude-reason"),
+		Types:               viper.GetStringSlice("type"),
+		ExcludeTypes:        viper.GetStringSlice("exclude-type"),
+	}
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+
+	policy := core.DefaultRetryPolicy()
+	policy.MaxRetries = viper.GetInt("max-retries")
+	policy.MaxWait = viper.GetDuration("rate-limit-wait")
+	transport := core.NewRateLimitTransport(http.DefaultTransport, policy, logger)
+	if !viper.GetBool("no-cache") {
+		transport = core.NewConditionalTransport(transport, cacheService, logger)
+	}
+	clientOpts := api.ClientOptions{Transport: transport}
+
+	client, err := api.NewRESTClient(clientOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	repoOpts := []core.RepositoryOption{
+		core.WithPerPage(viper.GetInt("per-page")),
+		core.WithMaxPages(viper.GetInt("max-pages")),
+		core.WithUnreadOnly(viper.GetBool("unread-only")),
+		core.WithParticipating(viper.GetBool("participating")),
+	}
+	if before := viper.GetString("before"); before != "" {
+		if viper.GetString("since") == core.SinceAuto {
+			return nil, fmt.Errorf("--before cannot be used with --since %s", core.SinceAuto)
+		}
+		// Checked here to fail early, but resolved on every sweep like since.
+		if _, err := core.ParseTime(before, time.Now()); err != nil {
+			return nil, fmt.Errorf("invalid --before: %w", err)
+		}
+		repoOpts = append(repoOpts, core.WithBefore(before))
+	}
+	notificationRepo := core.NewGithubRepository(client, repoOpts...)
+	var prService core.PRService = core.NewGithubPRService(client)
+	if viper.GetBool("graphql") {
+		gqlClient, err := api.NewGraphQLClient(clientOpts)
+		if err != nil {
+			return nil, err
+		}
+		prService = core.NewGraphQLPRService(gqlClient, prService)
+	}
+	issueService := core.NewGithubIssueService(client)
+	return core.NewNotificationService(notificationRepo, prService, issueService, cacheService,
+		core.WithStatusTTL(viper.GetDuration("status-ttl")),
+		core.WithRules(rules),
+		core.WithFilter(filter),
+		core.WithSubscription(subscription),
+		core.WithConcurrency(viper.GetInt("concurrency")),
+	), nil
+}
+
+// loadRules reads the rules section of the config file, falling back to the
+// built-in rules that clear merged pull requests and closed issues.
+func loadRules() (*core.RuleSet, error) {
+	action := viper.GetString("action")
+	if action != core.ActionDone && action != core.ActionRead {
+		return nil, fmt.Errorf("invalid action %q: must be %s or %s", action, core.ActionDone, core.ActionRead)
+	}
+
+	var rules []core.Rule
+	if err := viper.UnmarshalKey("rules", &rules); err != nil {
+		return nil, fmt.Errorf("invalid rules in config: %w", err)
+	}
+	for i := range rules {
+		if rules[i].Action == "" {
+			rules[i].Action = action
+		}
+	}
+	if len(rules) == 0 {
+		rules = core.DefaultRules(core.DefaultRuleOptions{
+			Action:                action,
+			ClearClosedPRs:        viper.GetBool("clear-closed-prs"),
+			ClearNotPlannedIssues: viper.GetBool("clear-not-planned"),
+		})
+	}
+	return core.NewRuleSet(rules)
+}
+
+func printPlan(w io.Writer, plan *core.Plan) {
+	for _, d := range plan.Decisions {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", d.Action, d.ThreadID, d.Reason, d.Title)
+	}
+	fmt.Fprintf(w, "%d notification(s) would be cleared\n", len(plan.Decisions))
+}
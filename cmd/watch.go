@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/gkwa/dailyare/core"
+)
+
+var watchCmd = &cobra.Command{
+	Use:   "watch",
+	Short: "Keep clearing notifications, polling GitHub on an interval",
+	Long: `Run a sweep, wait, and repeat until interrupted. The wait is the longer of --interval and the poll interval GitHub asks for in the X-Poll-Interval header.
+
+On SIGINT or SIGTERM the current sweep finishes and saves the cache before dailyare exits. A second signal exits immediately.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		logger := LoggerFrom(cmd.Context())
+
+		// The service is built once so the cache, rate limit state and
+		// conditional request validators carry over between sweeps.
+		cache, err := newCacheService(logger)
+		if err != nil {
+			return err
+		}
+
+		service, err := newNotificationService(logger, cache)
+		if err != nil {
+			return err
+		}
+
+		lock, err := lockRun(logger)
+		if err != nil {
+			return err
+		}
+		defer lock.Release()
+		defer cache.Close()
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		go func() {
+			<-ctx.Done()
+			logger.Info("Shutting down after the current sweep")
+			// Restore the default handlers so a second signal exits.
+			stop()
+		}()
+
+		interval := viper.GetDuration("interval")
+		for n := 1; ; n++ {
+			sweep(logger, service, n)
+
+			wait := max(interval, service.PollInterval())
+			logger.V(1).Info("Waiting for next sweep", "wait", wait.String())
+
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(wait):
+			}
+		}
+	},
+}
+
+// sweep runs one plan and apply cycle and logs a summary. Failures are logged
+// rather than returned so that watch keeps polling.
+func sweep(logger logr.Logger, service core.NotificationService, n int) {
+	start := time.Now()
+	logger = logger.WithValues("sweep", n)
+
+	summary, err := service.FetchNotifications(logger, viper.GetString("since"), viper.GetBool("no-cache"))
+	if summary == nil {
+		logger.Error(err, "Failed to fetch notifications")
+		return
+	}
+	if err != nil {
+		logger.Error(err, "Sweep finished with errors")
+	}
+
+	logger.Info("Sweep finished",
+		"fetched", summary.Fetched,
+		"skipped", summary.Skipped,
+		"checked", summary.Checked,
+		"cleared", summary.Cleared,
+		"failed", summary.Failed,
+		"duration", time.Since(start).Round(time.Millisecond).String())
+}
+
+func init() {
+	addSweepFlags(watchCmd.Flags())
+	watchCmd.Flags().Duration("interval", time.Minute, "Minimum time between sweeps; GitHub's X-Poll-Interval is used when longer")
+	rootCmd.AddCommand(watchCmd)
+}
@@ -1,39 +1,273 @@
 package core
 
 import (
+	"cmp"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
 )
 
+// cacheVersion is the version of the cache format written by Save. Bump it
+// and add a migration to cacheMigrations whenever stored data changes shape.
+const cacheVersion = 4
+
+// ErrCacheTooNew is returned by Load for a cache written by a newer dailyare,
+// which is left untouched rather than overwritten.
+var ErrCacheTooNew = errors.New("cache was written by a newer version of dailyare")
+
 type Cache struct {
-	PRStatus       map[string]bool `json:"pr_status"`
-	ThreadsDeleted map[string]bool `json:"threads_deleted"`
+	Version             int                       `json:"version"`
+	PRStatus            map[string]PRStatus       `json:"pr_status"`
+	IssueStatus         map[string]IssueStatus    `json:"issue_status"`
+	ThreadsDeleted      map[string]ClearedThread  `json:"threads_deleted"`
+	ThreadsUnsubscribed map[string]bool           `json:"threads_unsubscribed"`
+	Responses           map[string]CachedResponse `json:"responses"`
+	// LastSweep is the watermark of the last complete --since auto sweep.
+	LastSweep time.Time `json:"last_sweep,omitzero"`
+	// MaxWindow is the longest window any sweep has fetched. Entries unused
+	// for longer are evicted.
+	MaxWindow time.Duration `json:"max_window,omitzero"`
+	// Lookups counts cache hits and misses for each kind of entry.
+	Lookups map[string]Lookups `json:"lookups,omitempty"`
+}
+
+// Kinds of cache entries, as named by the cache command.
+const (
+	CacheKindPR           = "pr"
+	CacheKindIssue        = "issue"
+	CacheKindThread       = "thread"
+	CacheKindUnsubscribed = "unsubscribed"
+	CacheKindResponse     = "response"
+)
+
+// CacheKinds lists every kind of cache entry.
+var CacheKinds = []string{CacheKindPR, CacheKindIssue, CacheKindThread, CacheKindUnsubscribed, CacheKindResponse}
+
+// Lookups counts how often the cache had an entry that was asked for.
+type Lookups struct {
+	Hits   int `json:"hits"`
+	Misses int `json:"misses"`
+}
+
+// CacheEntry is one cached value, as listed by the cache command.
+type CacheEntry struct {
+	Kind   string          `json:"kind"`
+	Key    string          `json:"key"`
+	UsedAt time.Time       `json:"used_at,omitzero"`
+	Value  json.RawMessage `json:"value"`
+}
+
+// CacheStats summarizes the cache.
+type CacheStats struct {
+	// Location is where the cache is stored.
+	Location  string               `json:"location"`
+	Version   int                  `json:"version"`
+	LastSweep time.Time            `json:"last_sweep,omitzero"`
+	MaxWindow time.Duration        `json:"max_window,omitzero"`
+	Kinds     map[string]KindStats `json:"kinds"`
+}
+
+// KindStats summarizes the entries of one kind.
+type KindStats struct {
+	Entries int `json:"entries"`
+	Lookups
+	// HitRate is the fraction of lookups that found an entry.
+	HitRate float64 `json:"hit_rate"`
 }
 
+// ClearedThread records the updated_at a thread had when it was cleared.
+// GitHub reuses the thread when new activity arrives, so a later updated_at
+// means the thread needs evaluating again.
+type ClearedThread struct {
+	UpdatedAt time.Time `json:"updated_at"`
+	// UsedAt is when the cache last stored or returned the thread.
+	UsedAt time.Time `json:"used_at,omitzero"`
+}
+
+// CacheService stores what dailyare remembers between runs. Load must be
+// called before anything else, and the Cache it returns passed back to Save.
+// Implementations backed by a database do not load every entry into it.
 type CacheService interface {
 	Load() (*Cache, error)
 	Save(*Cache) error
-	IsThreadDeleted(id string) bool
-	SetThreadDeleted(id string)
-	GetPRStatus(url string) (bool, bool)
-	SetPRStatus(url string, merged bool)
+	// IsThreadDeleted reports whether the thread was cleared and has had no
+	// activity after updatedAt since.
+	IsThreadDeleted(id string, updatedAt time.Time) bool
+	SetThreadDeleted(id string, updatedAt time.Time)
+	// IsThreadReactivated reports whether the thread was cleared and has had
+	// activity since, so that it is now updated at updatedAt.
+	IsThreadReactivated(id string, updatedAt time.Time) bool
+	IsThreadUnsubscribed(id string) bool
+	SetThreadUnsubscribed(id string)
+	GetPRStatus(url string) (PRStatus, bool)
+	SetPRStatus(url string, status PRStatus)
+	GetIssueStatus(url string) (IssueStatus, bool)
+	SetIssueStatus(url string, status IssueStatus)
+	GetResponse(key string) (CachedResponse, bool)
+	SetResponse(key string, response CachedResponse)
+	GetLastSweep() time.Time
+	SetLastSweep(t time.Time)
+	GetMaxWindow() time.Duration
+	SetMaxWindow(window time.Duration)
+
+	// Stats summarizes the loaded cache.
+	Stats() CacheStats
+	// Entries returns the entries of a kind, or of every kind if kind is
+	// empty, sorted by kind and key.
+	Entries(kind string) ([]CacheEntry, error)
+	// Forget removes the entries with the key from a kind, or from every
+	// kind if kind is empty, and returns how many it removed.
+	Forget(kind, key string) (int, error)
+	// Prune applies the eviction policy now and returns how many entries of
+	// each kind it evicted.
+	Prune() (map[string]int, error)
+	// Clear removes every entry.
+	Clear() error
+	// Export writes the cache in the format of the cache file.
+	Export(w io.Writer) error
+	// Import replaces the cache with one written by Export, in the format
+	// of any version of the cache file.
+	Import(r io.Reader) error
+	// Close releases what the cache holds open. It does not save the cache.
+	Close() error
 }
 
+// fileCacheService is safe for concurrent use once loaded.
 type fileCacheService struct {
+	cacheOptions
+	mu        sync.RWMutex
 	cache     *Cache
 	cacheDir  string
 	cacheFile string
+	now       func() time.Time
+}
+
+// cacheOptions are the settings shared by every CacheService.
+type cacheOptions struct {
+	logger   logr.Logger
+	eviction EvictionPolicy
+}
+
+type CacheOption func(*cacheOptions)
+
+func newCacheOptions(opts []CacheOption) cacheOptions {
+	o := cacheOptions{logger: logr.Discard()}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// EvictionPolicy bounds how much the cache keeps. Save drops every entry
+// unused for longer than MaxAge or than the longest sweep window on record,
+// then the least recently used entries of any map holding more than
+// MaxEntries. Zero disables a bound.
+type EvictionPolicy struct {
+	MaxAge     time.Duration
+	MaxEntries int
+}
+
+// cutoff returns the time before which entries of a kind are evicted, or the
+// zero time if they do not expire. Cleared threads are only bound by MaxAge:
+// a thread with new activity is fetched again however old it is, and its
+// entry is what tells it apart from a new thread.
+func (p EvictionPolicy) cutoff(now time.Time, maxWindow time.Duration, kind string) time.Time {
+	var cutoff time.Time
+	if p.MaxAge > 0 {
+		cutoff = now.Add(-p.MaxAge)
+	}
+	if window := now.Add(-maxWindow); maxWindow > 0 && kind != CacheKindThread && window.After(cutoff) {
+		cutoff = window
+	}
+	return cutoff
+}
+
+// WithEviction sets the eviction policy applied on every Save.
+func WithEviction(policy EvictionPolicy) CacheOption {
+	return func(o *cacheOptions) {
+		o.eviction = policy
+	}
+}
+
+// WithCacheLogger sets the logger used to report a corrupt cache file,
+// migrations and evictions.
+func WithCacheLogger(logger logr.Logger) CacheOption {
+	return func(o *cacheOptions) {
+		o.logger = logger
+	}
 }
 
-func NewFileCacheService(homeDir string) CacheService {
+// CacheDir returns the directory holding the cache and the run lock.
+func CacheDir(homeDir string) string {
+	return filepath.Join(homeDir, ".dailyare")
+}
+
+func NewFileCacheService(homeDir string, opts ...CacheOption) CacheService {
 	return &fileCacheService{
-		cacheDir:  filepath.Join(homeDir, ".dailyare"),
-		cacheFile: "cache.json",
+		cacheOptions: newCacheOptions(opts),
+		cacheDir:     CacheDir(homeDir),
+		cacheFile:    "cache.json",
+		now:          time.Now,
+	}
+}
+
+func newCache() *Cache {
+	return &Cache{
+		PRStatus:            make(map[string]PRStatus),
+		IssueStatus:         make(map[string]IssueStatus),
+		ThreadsDeleted:      make(map[string]ClearedThread),
+		ThreadsUnsubscribed: make(map[string]bool),
+		Responses:           make(map[string]CachedResponse),
+		Lookups:             make(map[string]Lookups),
+		Version:             cacheVersion,
+	}
+}
+
+// ensureMaps replaces the maps a cache file left out with empty ones.
+func ensureMaps(c *Cache) {
+	if c.PRStatus == nil {
+		c.PRStatus = make(map[string]PRStatus)
+	}
+	if c.IssueStatus == nil {
+		c.IssueStatus = make(map[string]IssueStatus)
+	}
+	if c.ThreadsDeleted == nil {
+		c.ThreadsDeleted = make(map[string]ClearedThread)
+	}
+	if c.ThreadsUnsubscribed == nil {
+		c.ThreadsUnsubscribed = make(map[string]bool)
 	}
+	if c.Responses == nil {
+		c.Responses = make(map[string]CachedResponse)
+	}
+	if c.Lookups == nil {
+		c.Lookups = make(map[string]Lookups)
+	}
+}
+
+// touchUnusedEntries counts entries written before they carried a timestamp
+// as used now, so that they age out like any other.
+func touchUnusedEntries(c *Cache, now time.Time) {
+	touchUnused(c.PRStatus, now, prStatusUsedAt)
+	touchUnused(c.IssueStatus, now, issueStatusUsedAt)
+	touchUnused(c.ThreadsDeleted, now, clearedThreadUsedAt)
+	touchUnused(c.Responses, now, responseUsedAt)
 }
 
 func (s *fileCacheService) Load() (*Cache, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	if err := os.MkdirAll(s.cacheDir, 0o755); err != nil {
 		return nil, err
 	}
@@ -42,26 +276,46 @@ func (s *fileCacheService) Load() (*Cache, error) {
 	data, err := os.ReadFile(filePath)
 	if err != nil {
 		if os.IsNotExist(err) {
-			s.cache = &Cache{
-				PRStatus:       make(map[string]bool),
-				ThreadsDeleted: make(map[string]bool),
-			}
+			s.cache = newCache()
 			return s.cache, nil
 		}
 		return nil, err
 	}
 
-	if err := json.Unmarshal(data, &s.cache); err != nil {
-		return nil, err
+	cache, version, err := decodeCache(data)
+	if errors.Is(err, ErrCacheTooNew) {
+		return nil, fmt.Errorf("%s: %w", filePath, err)
 	}
-
-	if s.cache.PRStatus == nil {
-		s.cache.PRStatus = make(map[string]bool)
+	if err != nil {
+		// A cache that cannot be read should not stop every run. Keep it
+		// for inspection and start again from an empty one.
+		backup := fmt.Sprintf("%s.corrupt-%s", filePath, time.Now().Format("20060102T150405"))
+		if renameErr := os.Rename(filePath, backup); renameErr != nil {
+			return nil, fmt.Errorf("cache %s is corrupt (%w) and could not be moved aside: %w", filePath, err, renameErr)
+		}
+		s.logger.Info("Cache is corrupt, starting with an empty one", "error", err.Error(), "backup", backup)
+		s.cache = newCache()
+		return s.cache, nil
 	}
-	if s.cache.ThreadsDeleted == nil {
-		s.cache.ThreadsDeleted = make(map[string]bool)
+	s.cache = cache
+	ensureMaps(s.cache)
+
+	if version < cacheVersion {
+		backup := fmt.Sprintf("%s.v%d.bak", filePath, version)
+		if err := os.WriteFile(backup, data, 0o644); err != nil {
+			return nil, fmt.Errorf("failed to back up cache before migrating it: %w", err)
+		}
+		migrated, err := json.MarshalIndent(cache, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		if err := writeFileAtomic(filePath, migrated, 0o644); err != nil {
+			return nil, fmt.Errorf("failed to write migrated cache: %w", err)
+		}
+		s.logger.Info("Migrated cache", "from", version, "to", cacheVersion, "backup", backup)
 	}
 
+	touchUnusedEntries(s.cache, s.now())
 	return s.cache, nil
 }
 
@@ -70,27 +324,570 @@ func (s *fileCacheService) Save(cache *Cache) error {
 		return err
 	}
 
+	s.mu.Lock()
+	cache.Version = cacheVersion
+	evicted := s.evict(cache)
 	data, err := json.MarshalIndent(cache, "", "  ")
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	logEvicted(s.logger, evicted)
+	return writeFileAtomic(s.path(), data, 0o644)
+}
+
+func (s *fileCacheService) path() string {
+	return filepath.Join(s.cacheDir, s.cacheFile)
+}
+
+func logEvicted(logger logr.Logger, evicted map[string]int) {
+	var total int
+	var keysAndValues []any
+	for _, kind := range CacheKinds {
+		total += evicted[kind]
+		keysAndValues = append(keysAndValues, kind, evicted[kind])
+	}
+	if total > 0 {
+		logger.V(1).Info("Evicted cache entries", keysAndValues...)
+	}
+}
+
+// evict applies the eviction policy to cache and returns how many entries
+// of each kind it dropped. Unsubscribed threads go with their cleared thread
+// entries.
+func (s *fileCacheService) evict(cache *Cache) map[string]int {
+	now := s.now()
+	cutoff := func(kind string) time.Time {
+		return s.eviction.cutoff(now, cache.MaxWindow, kind)
+	}
+
+	maxEntries := s.eviction.MaxEntries
+	counts := map[string]int{
+		CacheKindPR:       evictEntries(cache.PRStatus, cutoff(CacheKindPR), maxEntries, prStatusUsedAt),
+		CacheKindIssue:    evictEntries(cache.IssueStatus, cutoff(CacheKindIssue), maxEntries, issueStatusUsedAt),
+		CacheKindThread:   evictEntries(cache.ThreadsDeleted, cutoff(CacheKindThread), maxEntries, clearedThreadUsedAt),
+		CacheKindResponse: evictEntries(cache.Responses, cutoff(CacheKindResponse), maxEntries, responseUsedAt),
+	}
+	n := len(cache.ThreadsUnsubscribed)
+	maps.DeleteFunc(cache.ThreadsUnsubscribed, func(id string, _ bool) bool {
+		_, cleared := cache.ThreadsDeleted[id]
+		return !cleared
+	})
+	counts[CacheKindUnsubscribed] = n - len(cache.ThreadsUnsubscribed)
+	return counts
+}
+
+// evictEntries drops the entries used before cutoff, then the least recently
+// used ones beyond maxEntries, and returns how many it dropped.
+func evictEntries[V any](entries map[string]V, cutoff time.Time, maxEntries int, usedAt func(*V) *time.Time) int {
+	n := len(entries)
+	maps.DeleteFunc(entries, func(_ string, v V) bool {
+		return usedAt(&v).Before(cutoff)
+	})
+
+	if maxEntries > 0 && len(entries) > maxEntries {
+		keys := slices.SortedFunc(maps.Keys(entries), func(a, b string) int {
+			va, vb := entries[a], entries[b]
+			return cmp.Or(usedAt(&vb).Compare(*usedAt(&va)), strings.Compare(a, b))
+		})
+		for _, key := range keys[maxEntries:] {
+			delete(entries, key)
+		}
+	}
+	return n - len(entries)
+}
+
+// touchUnused sets the timestamp of entries that have none to now.
+func touchUnused[V any](entries map[string]V, now time.Time, usedAt func(*V) *time.Time) {
+	for key, v := range entries {
+		if t := usedAt(&v); t.IsZero() {
+			*t = now
+			entries[key] = v
+		}
+	}
+}
+
+func prStatusUsedAt(v *PRStatus) *time.Time           { return &v.UsedAt }
+func issueStatusUsedAt(v *IssueStatus) *time.Time     { return &v.UsedAt }
+func clearedThreadUsedAt(v *ClearedThread) *time.Time { return &v.UsedAt }
+func responseUsedAt(v *CachedResponse) *time.Time     { return &v.UsedAt }
+
+// decodeCache parses a cache file of any version and upgrades it to the
+// current one, returning the version the file was written in.
+func decodeCache(data []byte) (*Cache, int, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, 0, err
+	}
+
+	version := 1
+	if v, ok := raw["version"]; ok {
+		if err := json.Unmarshal(v, &version); err != nil {
+			return nil, 0, fmt.Errorf("invalid cache version: %w", err)
+		}
+	}
+	if version < 1 {
+		return nil, version, fmt.Errorf("invalid cache version: %d", version)
+	}
+	if version > cacheVersion {
+		return nil, version, fmt.Errorf("%w: version %d, this dailyare reads up to %d", ErrCacheTooNew, version, cacheVersion)
+	}
+
+	for v := version; v < cacheVersion; v++ {
+		if err := cacheMigrations[v-1](raw); err != nil {
+			return nil, version, fmt.Errorf("failed to migrate cache from version %d: %w", v, err)
+		}
+	}
+
+	migrated, err := json.Marshal(raw)
+	if err != nil {
+		return nil, version, err
+	}
+	var cache Cache
+	if err := json.Unmarshal(migrated, &cache); err != nil {
+		return nil, version, err
+	}
+	cache.Version = cacheVersion
+	return &cache, version, nil
+}
+
+// cacheMigrations[i] upgrades a raw cache from version i+1 to i+2. Caches
+// written before the version field existed are version 1 whichever of the
+// unversioned formats they are in, so each migration only rewrites entries
+// still in its old shape.
+var cacheMigrations = []func(raw map[string]json.RawMessage) error{
+	// PR statuses were a bare merged bool.
+	func(raw map[string]json.RawMessage) error {
+		return migrateEntries(raw, "pr_status", func(entry json.RawMessage) (any, error) {
+			var merged bool
+			if json.Unmarshal(entry, &merged) != nil {
+				return entry, nil
+			}
+			return map[string]bool{"merged": merged}, nil
+		})
+	},
+	// PR statuses had a merged field instead of a state. Unmerged entries
+	// cannot tell closed from open PRs, so they lose their fetch time and
+	// are refreshed on the next run.
+	func(raw map[string]json.RawMessage) error {
+		return migrateEntries(raw, "pr_status", func(entry json.RawMessage) (any, error) {
+			var fields map[string]json.RawMessage
+			if err := json.Unmarshal(entry, &fields); err != nil {
+				return nil, err
+			}
+			if _, ok := fields["state"]; ok {
+				return entry, nil
+			}
+
+			var merged bool
+			if m, ok := fields["merged"]; ok {
+				if err := json.Unmarshal(m, &merged); err != nil {
+					return nil, err
+				}
+			}
+			delete(fields, "merged")
+			fields["state"] = json.RawMessage(`"` + PRStateMerged + `"`)
+			if !merged {
+				fields["state"] = json.RawMessage(`"` + PRStateOpen + `"`)
+				delete(fields, "fetched_at")
+			}
+			return fields, nil
+		})
+	},
+	// Cleared threads were true rather than the updated_at they had when
+	// cleared. They are left with a zero updated_at and adopt the one they
+	// are next fetched with, see IsThreadDeleted.
+	func(raw map[string]json.RawMessage) error {
+		return migrateEntries(raw, "threads_deleted", func(entry json.RawMessage) (any, error) {
+			var cleared bool
+			if json.Unmarshal(entry, &cleared) != nil {
+				return entry, nil
+			}
+			return ClearedThread{}, nil
+		})
+	},
+}
+
+// migrateEntries replaces every entry of the map stored under field with the
+// result of fn.
+func migrateEntries(raw map[string]json.RawMessage, field string, fn func(json.RawMessage) (any, error)) error {
+	data, ok := raw[field]
+	if !ok {
+		return nil
+	}
+	var entries map[string]json.RawMessage
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return fmt.Errorf("%s: %w", field, err)
+	}
+
+	migrated := make(map[string]any, len(entries))
+	for key, entry := range entries {
+		v, err := fn(entry)
+		if err != nil {
+			return fmt.Errorf("%s %s: %w", field, key, err)
+		}
+		migrated[key] = v
+	}
+
+	data, err := json.Marshal(migrated)
+	if err != nil {
+		return err
+	}
+	raw[field] = data
+	return nil
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames it
+// into place, so an interrupted write never leaves path truncated.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
 	if err != nil {
 		return err
 	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (s *fileCacheService) IsThreadDeleted(id string, updatedAt time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cleared, exists := s.cache.ThreadsDeleted[id]
+	if exists {
+		// Threads cleared before their updated_at was recorded adopt the
+		// one they are next fetched with instead of being evaluated again.
+		if cleared.UpdatedAt.IsZero() {
+			cleared.UpdatedAt = updatedAt
+		}
+		cleared.UsedAt = s.now()
+		s.cache.ThreadsDeleted[id] = cleared
+	}
+	deleted := exists && !updatedAt.After(cleared.UpdatedAt)
+	s.recordLookup(CacheKindThread, deleted)
+	return deleted
+}
 
-	return os.WriteFile(filepath.Join(s.cacheDir, s.cacheFile), data, 0o644)
+func (s *fileCacheService) IsThreadReactivated(id string, updatedAt time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cleared, exists := s.cache.ThreadsDeleted[id]
+	return exists && !cleared.UpdatedAt.IsZero() && updatedAt.After(cleared.UpdatedAt)
 }
 
-func (s *fileCacheService) IsThreadDeleted(id string) bool {
-	return s.cache.ThreadsDeleted[id]
+func (s *fileCacheService) SetThreadDeleted(id string, updatedAt time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cache.ThreadsDeleted[id] = ClearedThread{UpdatedAt: updatedAt, UsedAt: s.now()}
 }
 
-func (s *fileCacheService) SetThreadDeleted(id string) {
-	s.cache.ThreadsDeleted[id] = true
+func (s *fileCacheService) IsThreadUnsubscribed(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	unsubscribed := s.cache.ThreadsUnsubscribed[id]
+	s.recordLookup(CacheKindUnsubscribed, unsubscribed)
+	return unsubscribed
 }
 
-func (s *fileCacheService) GetPRStatus(url string) (bool, bool) {
+func (s *fileCacheService) SetThreadUnsubscribed(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cache.ThreadsUnsubscribed[id] = true
+}
+
+func (s *fileCacheService) GetPRStatus(url string) (PRStatus, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	status, exists := s.cache.PRStatus[url]
+	if exists {
+		status.UsedAt = s.now()
+		s.cache.PRStatus[url] = status
+	}
+	s.recordLookup(CacheKindPR, exists)
+	return status, exists
+}
+
+func (s *fileCacheService) SetPRStatus(url string, status PRStatus) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status.UsedAt = s.now()
+	s.cache.PRStatus[url] = status
+}
+
+func (s *fileCacheService) GetIssueStatus(url string) (IssueStatus, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status, exists := s.cache.IssueStatus[url]
+	if exists {
+		status.UsedAt = s.now()
+		s.cache.IssueStatus[url] = status
+	}
+	s.recordLookup(CacheKindIssue, exists)
 	return status, exists
 }
 
-func (s *fileCacheService) SetPRStatus(url string, merged bool) {
-	s.cache.PRStatus[url] = merged
+func (s *fileCacheService) SetIssueStatus(url string, status IssueStatus) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status.UsedAt = s.now()
+	s.cache.IssueStatus[url] = status
+}
+
+func (s *fileCacheService) GetResponse(key string) (CachedResponse, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	response, exists := s.cache.Responses[key]
+	if exists {
+		response.UsedAt = s.now()
+		s.cache.Responses[key] = response
+	}
+	s.recordLookup(CacheKindResponse, exists)
+	return response, exists
+}
+
+func (s *fileCacheService) SetResponse(key string, response CachedResponse) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	response.UsedAt = s.now()
+	s.cache.Responses[key] = response
+}
+
+func (s *fileCacheService) GetLastSweep() time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.cache.LastSweep
+}
+
+// SetLastSweep never moves the watermark backwards.
+func (s *fileCacheService) SetLastSweep(t time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if t.After(s.cache.LastSweep) {
+		s.cache.LastSweep = t
+	}
+}
+
+func (s *fileCacheService) GetMaxWindow() time.Duration {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.cache.MaxWindow
+}
+
+// SetMaxWindow records the window of a sweep, keeping the longest.
+func (s *fileCacheService) SetMaxWindow(window time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cache.MaxWindow = max(s.cache.MaxWindow, window)
+}
+
+// recordLookup counts a hit or miss. The caller holds the write lock.
+func (s *fileCacheService) recordLookup(kind string, hit bool) {
+	lookups := s.cache.Lookups[kind]
+	if hit {
+		lookups.Hits++
+	} else {
+		lookups.Misses++
+	}
+	s.cache.Lookups[kind] = lookups
+}
+
+func (s *fileCacheService) Stats() CacheStats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stats := CacheStats{
+		Location:  s.path(),
+		Version:   s.cache.Version,
+		LastSweep: s.cache.LastSweep,
+		MaxWindow: s.cache.MaxWindow,
+		Kinds:     make(map[string]KindStats),
+	}
+	sizes := map[string]int{
+		CacheKindPR:           len(s.cache.PRStatus),
+		CacheKindIssue:        len(s.cache.IssueStatus),
+		CacheKindThread:       len(s.cache.ThreadsDeleted),
+		CacheKindUnsubscribed: len(s.cache.ThreadsUnsubscribed),
+		CacheKindResponse:     len(s.cache.Responses),
+	}
+	for _, kind := range CacheKinds {
+		stats.Kinds[kind] = newKindStats(sizes[kind], s.cache.Lookups[kind])
+	}
+	return stats
+}
+
+func newKindStats(entries int, lookups Lookups) KindStats {
+	stats := KindStats{Entries: entries, Lookups: lookups}
+	if total := lookups.Hits + lookups.Misses; total > 0 {
+		stats.HitRate = float64(lookups.Hits) / float64(total)
+	}
+	return stats
+}
+
+func (s *fileCacheService) Entries(kind string) ([]CacheEntry, error) {
+	kinds, err := selectKinds(kind)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return cacheEntries(s.cache, kinds)
+}
+
+// cacheEntries returns the entries of the kinds in cache.
+func cacheEntries(cache *Cache, kinds []string) ([]CacheEntry, error) {
+	var entries []CacheEntry
+	for _, kind := range kinds {
+		var err error
+		switch kind {
+		case CacheKindPR:
+			entries, err = appendEntries(entries, kind, cache.PRStatus, prStatusUsedAt)
+		case CacheKindIssue:
+			entries, err = appendEntries(entries, kind, cache.IssueStatus, issueStatusUsedAt)
+		case CacheKindThread:
+			entries, err = appendEntries(entries, kind, cache.ThreadsDeleted, clearedThreadUsedAt)
+		case CacheKindUnsubscribed:
+			entries, err = appendEntries(entries, kind, cache.ThreadsUnsubscribed, nil)
+		case CacheKindResponse:
+			entries, err = appendEntries(entries, kind, cache.Responses, responseUsedAt)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return entries, nil
+}
+
+// appendEntries appends the entries of one map, sorted by key. usedAt may be
+// nil for entries without a timestamp.
+func appendEntries[V any](entries []CacheEntry, kind string, m map[string]V, usedAt func(*V) *time.Time) ([]CacheEntry, error) {
+	for _, key := range slices.Sorted(maps.Keys(m)) {
+		v := m[key]
+		value, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		entry := CacheEntry{Kind: kind, Key: key, Value: value}
+		if usedAt != nil {
+			entry.UsedAt = *usedAt(&v)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func (s *fileCacheService) Forget(kind, key string) (int, error) {
+	kinds, err := selectKinds(kind)
+	if err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var n int
+	for _, kind := range kinds {
+		n += forgetEntry(s.cache, kind, key)
+	}
+	return n, nil
+}
+
+func forgetEntry(c *Cache, kind, key string) int {
+	switch kind {
+	case CacheKindPR:
+		return deleteKey(c.PRStatus, key)
+	case CacheKindIssue:
+		return deleteKey(c.IssueStatus, key)
+	case CacheKindThread:
+		return deleteKey(c.ThreadsDeleted, key)
+	case CacheKindUnsubscribed:
+		return deleteKey(c.ThreadsUnsubscribed, key)
+	case CacheKindResponse:
+		return deleteKey(c.Responses, key)
+	}
+	return 0
+}
+
+func deleteKey[V any](m map[string]V, key string) int {
+	if _, ok := m[key]; !ok {
+		return 0
+	}
+	delete(m, key)
+	return 1
+}
+
+// selectKinds returns the kinds named by kind, where empty means all.
+func selectKinds(kind string) ([]string, error) {
+	if kind == "" {
+		return CacheKinds, nil
+	}
+	if !slices.Contains(CacheKinds, kind) {
+		return nil, fmt.Errorf("invalid cache kind %q: must be one of %s", kind, strings.Join(CacheKinds, ", "))
+	}
+	return []string{kind}, nil
+}
+
+func (s *fileCacheService) Prune() (map[string]int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.evict(s.cache), nil
+}
+
+// Clear empties the loaded cache in place, so that callers holding it from
+// Load save the empty cache.
+func (s *fileCacheService) Clear() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	*s.cache = *newCache()
+	return nil
+}
+
+// Close does nothing, as the file is only open while it is read or written.
+func (s *fileCacheService) Close() error {
+	return nil
+}
+
+func (s *fileCacheService) Export(w io.Writer) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	data, err := json.MarshalIndent(s.cache, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// Import replaces the loaded cache in place, like Clear.
+func (s *fileCacheService) Import(r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	cache, _, err := decodeCache(data)
+	if err != nil {
+		return fmt.Errorf("invalid cache: %w", err)
+	}
+	ensureMaps(cache)
+	touchUnusedEntries(cache, s.now())
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	*s.cache = *cache
+	return nil
 }
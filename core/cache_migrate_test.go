@@ -0,0 +1,106 @@
+package core
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "update golden files in testdata")
+
+// TestFileCacheService_Migrate loads each historical cache format from
+// testdata/cache and compares the file it is upgraded to with its golden
+// file. Run with -update to regenerate the golden files.
+func TestFileCacheService_Migrate(t *testing.T) {
+	inputs, err := filepath.Glob(filepath.Join("testdata", "cache", "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, input := range inputs {
+		if strings.HasSuffix(input, ".golden.json") {
+			continue
+		}
+		name := strings.TrimSuffix(filepath.Base(input), ".json")
+		t.Run(name, func(t *testing.T) {
+			original, err := os.ReadFile(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			tmpDir := t.TempDir()
+			cachePath := writeTestCache(t, tmpDir, original)
+
+			if _, err := NewFileCacheService(tmpDir).Load(); err != nil {
+				t.Fatalf("Failed to load cache: %v", err)
+			}
+
+			got, err := os.ReadFile(cachePath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			golden := filepath.Join("testdata", "cache", name+".golden.json")
+			if *updateGolden {
+				if err := os.WriteFile(golden, got, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != string(want) {
+				t.Errorf("Migrated cache does not match %s:\n%s", golden, got)
+			}
+
+			backup, err := os.ReadFile(cachePath + ".v1.bak")
+			if err != nil {
+				t.Fatalf("Expected a backup of the original cache: %v", err)
+			}
+			if string(backup) != string(original) {
+				t.Error("Expected the backup to keep the original cache")
+			}
+
+			// The migrated file must load as the current version, without
+			// another migration.
+			if err := os.Remove(cachePath + ".v1.bak"); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := NewFileCacheService(tmpDir).Load(); err != nil {
+				t.Fatalf("Failed to reload migrated cache: %v", err)
+			}
+			if _, err := os.Stat(cachePath + ".v1.bak"); !os.IsNotExist(err) {
+				t.Error("Expected the migrated cache not to be migrated again")
+			}
+		})
+	}
+}
+
+func TestFileCacheService_LoadNewerVersion(t *testing.T) {
+	tmpDir := t.TempDir()
+	newer := `{"version":99,"pr_status":{}}`
+	cachePath := writeTestCache(t, tmpDir, []byte(newer))
+
+	_, err := NewFileCacheService(tmpDir).Load()
+	if !errors.Is(err, ErrCacheTooNew) {
+		t.Fatalf("Expected ErrCacheTooNew, got %v", err)
+	}
+	if data, _ := os.ReadFile(cachePath); string(data) != newer {
+		t.Errorf("Expected a newer cache to be left untouched, got %s", data)
+	}
+}
+
+func writeTestCache(t *testing.T, homeDir string, data []byte) string {
+	t.Helper()
+	cacheDir := CacheDir(homeDir)
+	if err := os.MkdirAll(cacheDir, 0o755); err != nil {
+		t.Fatalf("Failed to create cache dir: %v", err)
+	}
+	cachePath := filepath.Join(cacheDir, "cache.json")
+	if err := os.WriteFile(cachePath, data, 0o644); err != nil {
+		t.Fatalf("Failed to write cache: %v", err)
+	}
+	return cachePath
+}
@@ -1,7 +1,15 @@
 package core
 
 import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestFileCacheService_LoadNoExistingCache(t *testing.T) {
@@ -26,12 +34,12 @@ func TestFileCacheService_SaveAndLoad(t *testing.T) {
 	svc := NewFileCacheService(tmpDir)
 
 	cache := &Cache{
-		PRStatus: map[string]bool{
-			"pr1": true,
-			"pr2": false,
+		PRStatus: map[string]PRStatus{
+			"pr1": {State: PRStateMerged},
+			"pr2": {State: PRStateOpen, FetchedAt: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)},
 		},
-		ThreadsDeleted: map[string]bool{
-			"thread1": true,
+		ThreadsDeleted: map[string]ClearedThread{
+			"thread1": {UpdatedAt: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)},
 		},
 	}
 
@@ -47,13 +55,396 @@ func TestFileCacheService_SaveAndLoad(t *testing.T) {
 	if len(loaded.PRStatus) != 2 {
 		t.Errorf("Expected 2 PR statuses, got %d", len(loaded.PRStatus))
 	}
-	if !loaded.PRStatus["pr1"] {
-		t.Error("Expected pr1 to be true")
+	if loaded.PRStatus["pr1"].State != PRStateMerged {
+		t.Error("Expected pr1 to be merged")
 	}
-	if loaded.PRStatus["pr2"] {
-		t.Error("Expected pr2 to be false")
+	if loaded.PRStatus["pr2"].State != PRStateOpen {
+		t.Error("Expected pr2 to be open")
 	}
-	if !loaded.ThreadsDeleted["thread1"] {
-		t.Error("Expected thread1 to be true")
+	if !loaded.PRStatus["pr2"].FetchedAt.Equal(cache.PRStatus["pr2"].FetchedAt) {
+		t.Errorf("Expected pr2 fetched at %v, got %v", cache.PRStatus["pr2"].FetchedAt, loaded.PRStatus["pr2"].FetchedAt)
+	}
+	if !loaded.ThreadsDeleted["thread1"].UpdatedAt.Equal(cache.ThreadsDeleted["thread1"].UpdatedAt) {
+		t.Errorf("Expected thread1 cleared at %v, got %+v", cache.ThreadsDeleted["thread1"].UpdatedAt, loaded.ThreadsDeleted["thread1"])
+	}
+}
+
+func TestFileCacheService_LoadLegacyBoolStatus(t *testing.T) {
+	tmpDir := t.TempDir()
+	cacheDir := filepath.Join(tmpDir, ".dailyare")
+	if err := os.MkdirAll(cacheDir, 0o755); err != nil {
+		t.Fatalf("Failed to create cache dir: %v", err)
+	}
+
+	legacy := `{"pr_status":{"pr1":true,"pr2":false},"threads_deleted":{"thread1":true}}`
+	if err := os.WriteFile(filepath.Join(cacheDir, "cache.json"), []byte(legacy), 0o644); err != nil {
+		t.Fatalf("Failed to write legacy cache: %v", err)
+	}
+
+	loaded, err := NewFileCacheService(tmpDir).Load()
+	if err != nil {
+		t.Fatalf("Failed to load legacy cache: %v", err)
+	}
+
+	if loaded.PRStatus["pr1"].State != PRStateMerged {
+		t.Error("Expected pr1 to be merged")
+	}
+	if loaded.PRStatus["pr2"].State != PRStateOpen {
+		t.Error("Expected pr2 to be open")
+	}
+	if !loaded.PRStatus["pr2"].FetchedAt.IsZero() {
+		t.Error("Expected legacy entries to have no fetch time")
+	}
+	if thread, ok := loaded.ThreadsDeleted["thread1"]; !ok || !thread.UpdatedAt.IsZero() {
+		t.Errorf("Expected thread1 to be cleared without an updated_at, got %+v", thread)
+	}
+}
+
+func TestFileCacheService_LoadMergedFieldStatus(t *testing.T) {
+	tmpDir := t.TempDir()
+	cacheDir := filepath.Join(tmpDir, ".dailyare")
+	if err := os.MkdirAll(cacheDir, 0o755); err != nil {
+		t.Fatalf("Failed to create cache dir: %v", err)
+	}
+
+	legacy := `{"pr_status":{
+		"pr1":{"merged":true,"fetched_at":"2024-01-02T03:04:05Z"},
+		"pr2":{"merged":false,"fetched_at":"2024-01-02T03:04:05Z"}
+	}}`
+	if err := os.WriteFile(filepath.Join(cacheDir, "cache.json"), []byte(legacy), 0o644); err != nil {
+		t.Fatalf("Failed to write legacy cache: %v", err)
+	}
+
+	loaded, err := NewFileCacheService(tmpDir).Load()
+	if err != nil {
+		t.Fatalf("Failed to load legacy cache: %v", err)
+	}
+
+	if pr1 := loaded.PRStatus["pr1"]; pr1.State != PRStateMerged || pr1.FetchedAt.IsZero() {
+		t.Errorf("Expected pr1 to stay merged with its fetch time, got %+v", pr1)
+	}
+	if pr2 := loaded.PRStatus["pr2"]; pr2.State != PRStateOpen || !pr2.FetchedAt.IsZero() {
+		t.Errorf("Expected pr2 to be open and due for a refresh, got %+v", pr2)
+	}
+}
+
+func TestFileCacheService_ConcurrentAccess(t *testing.T) {
+	svc := NewFileCacheService(t.TempDir())
+	cache, err := svc.Load()
+	if err != nil {
+		t.Fatalf("Failed to load cache: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Go(func() {
+			id := fmt.Sprint(i)
+			svc.SetThreadDeleted(id, time.Now())
+			svc.SetPRStatus("pr"+id, PRStatus{State: PRStateMerged})
+			svc.IsThreadDeleted(id, time.Now())
+			svc.GetPRStatus("pr" + id)
+			if err := svc.Save(cache); err != nil {
+				t.Errorf("Failed to save cache: %v", err)
+			}
+		})
+	}
+	wg.Wait()
+
+	if len(cache.ThreadsDeleted) != 20 || len(cache.PRStatus) != 20 {
+		t.Errorf("Expected 20 threads and PRs, got %d and %d", len(cache.ThreadsDeleted), len(cache.PRStatus))
+	}
+}
+
+func TestFileCacheService_IsThreadDeleted(t *testing.T) {
+	svc := NewFileCacheService(t.TempDir())
+	if _, err := svc.Load(); err != nil {
+		t.Fatalf("Failed to load cache: %v", err)
+	}
+
+	clearedAt := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	svc.SetThreadDeleted("1", clearedAt)
+
+	tests := []struct {
+		name      string
+		id        string
+		updatedAt time.Time
+		want      bool
+	}{
+		{"same activity", "1", clearedAt, true},
+		{"older activity", "1", clearedAt.Add(-time.Hour), true},
+		{"new activity", "1", clearedAt.Add(time.Minute), false},
+		{"never cleared", "2", clearedAt, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := svc.IsThreadDeleted(tt.id, tt.updatedAt); got != tt.want {
+				t.Errorf("IsThreadDeleted() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFileCacheService_LegacyClearedThread(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeTestCache(t, tmpDir, []byte(`{"threads_deleted":{"1":true}}`))
+
+	svc := NewFileCacheService(tmpDir)
+	if _, err := svc.Load(); err != nil {
+		t.Fatalf("Failed to load cache: %v", err)
+	}
+
+	// Cleared before updated_at was recorded, so the first fetch is taken
+	// as the activity it was cleared with.
+	updatedAt := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	if !svc.IsThreadDeleted("1", updatedAt) {
+		t.Error("Expected a thread cleared before the upgrade to stay cleared")
+	}
+	if svc.IsThreadReactivated("1", updatedAt) {
+		t.Error("Expected a thread cleared before the upgrade not to be reactivated")
+	}
+	if !svc.IsThreadReactivated("1", updatedAt.Add(time.Minute)) {
+		t.Error("Expected later activity to reactivate the thread")
+	}
+}
+
+func TestFileCacheService_RecoversFromCorruptCache(t *testing.T) {
+	tmpDir := t.TempDir()
+	cacheDir := CacheDir(tmpDir)
+	if err := os.MkdirAll(cacheDir, 0o755); err != nil {
+		t.Fatalf("Failed to create cache dir: %v", err)
+	}
+	cachePath := filepath.Join(cacheDir, "cache.json")
+	if err := os.WriteFile(cachePath, []byte(`{"pr_status":{"pr1":`), 0o644); err != nil {
+		t.Fatalf("Failed to write corrupt cache: %v", err)
+	}
+
+	cache, err := NewFileCacheService(tmpDir).Load()
+	if err != nil {
+		t.Fatalf("Expected a corrupt cache to be recovered, got %v", err)
+	}
+	if len(cache.PRStatus) != 0 || cache.ThreadsDeleted == nil {
+		t.Errorf("Expected an empty, usable cache, got %+v", cache)
+	}
+
+	backups, _ := filepath.Glob(cachePath + ".corrupt-*")
+	if len(backups) != 1 {
+		t.Fatalf("Expected the corrupt cache to be backed up, got %v", backups)
+	}
+	if data, _ := os.ReadFile(backups[0]); string(data) != `{"pr_status":{"pr1":` {
+		t.Errorf("Expected the backup to keep the corrupt contents, got %q", data)
+	}
+}
+
+func TestFileCacheService_SaveLeavesNoTempFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	svc := NewFileCacheService(tmpDir)
+	cache, err := svc.Load()
+	if err != nil {
+		t.Fatalf("Failed to load cache: %v", err)
+	}
+
+	for range 3 {
+		if err := svc.Save(cache); err != nil {
+			t.Fatalf("Failed to save cache: %v", err)
+		}
+	}
+
+	entries, err := os.ReadDir(CacheDir(tmpDir))
+	if err != nil {
+		t.Fatalf("Failed to read cache dir: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "cache.json" {
+		var names []string
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		t.Errorf("Expected only cache.json, got %v", names)
+	}
+}
+
+func TestFileCacheService_Evict(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	svc := NewFileCacheService(t.TempDir(), WithEviction(EvictionPolicy{MaxAge: 30 * day, MaxEntries: 3}))
+	fs := svc.(*fileCacheService)
+	cache, err := svc.Load()
+	if err != nil {
+		t.Fatalf("Failed to load cache: %v", err)
+	}
+
+	at := func(ago time.Duration) { fs.now = func() time.Time { return now.Add(-ago) } }
+
+	at(40 * day)
+	svc.SetPRStatus("old", PRStatus{State: PRStateMerged})
+	svc.SetPRStatus("old-but-read", PRStatus{State: PRStateMerged})
+	svc.SetThreadDeleted("old-thread", now.Add(-41*day))
+	svc.SetThreadUnsubscribed("old-thread")
+	svc.SetResponse("old-response", CachedResponse{ETag: `"a"`})
+	for i, ago := range []time.Duration{4 * day, 3 * day, 2 * day, day} {
+		at(ago)
+		svc.SetPRStatus(fmt.Sprint("recent", i), PRStatus{State: PRStateMerged})
+	}
+	at(day)
+	svc.GetPRStatus("old-but-read")
+
+	fs.now = func() time.Time { return now }
+	if err := svc.Save(cache); err != nil {
+		t.Fatalf("Failed to save cache: %v", err)
+	}
+
+	var kept []string
+	for url := range cache.PRStatus {
+		kept = append(kept, url)
+	}
+	slices.Sort(kept)
+	if want := []string{"old-but-read", "recent2", "recent3"}; !slices.Equal(kept, want) {
+		t.Errorf("Expected PR statuses %v to be kept, got %v", want, kept)
+	}
+	if len(cache.ThreadsDeleted) != 0 || len(cache.ThreadsUnsubscribed) != 0 {
+		t.Errorf("Expected the old thread to be evicted with its subscription, got %v and %v", cache.ThreadsDeleted, cache.ThreadsUnsubscribed)
+	}
+	if len(cache.Responses) != 0 {
+		t.Errorf("Expected the old response to be evicted, got %v", cache.Responses)
+	}
+}
+
+func TestFileCacheService_EvictOutsideMaxWindow(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		maxAge      time.Duration
+		wantIssues  []string
+		wantThreads []string
+	}{
+		// The window evicts statuses sooner than the max age, but cleared
+		// threads are kept for as long as the max age allows.
+		{"max age longer than window", 30 * day, []string{"5d"}, []string{"10d", "5d"}},
+		{"window longer than max age", 2 * day, nil, nil},
+		{"no max age", 0, []string{"5d"}, []string{"40d", "10d", "5d"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := NewFileCacheService(t.TempDir(), WithEviction(EvictionPolicy{MaxAge: tt.maxAge}))
+			fs := svc.(*fileCacheService)
+			cache, err := svc.Load()
+			if err != nil {
+				t.Fatalf("Failed to load cache: %v", err)
+			}
+
+			ages := []string{"40d", "10d", "5d"}
+			for _, age := range []time.Duration{40 * day, 10 * day, 5 * day} {
+				fs.now = func() time.Time { return now.Add(-age) }
+				key := fmt.Sprintf("%dd", age/day)
+				svc.SetIssueStatus(key, IssueStatus{Closed: true})
+				svc.SetThreadDeleted(key, now.Add(-age))
+			}
+
+			fs.now = func() time.Time { return now }
+			svc.SetMaxWindow(7 * day)
+			if err := svc.Save(cache); err != nil {
+				t.Fatalf("Failed to save cache: %v", err)
+			}
+
+			var issues, threads []string
+			for _, age := range ages {
+				if _, ok := cache.IssueStatus[age]; ok {
+					issues = append(issues, age)
+				}
+				if _, ok := cache.ThreadsDeleted[age]; ok {
+					threads = append(threads, age)
+				}
+			}
+			if !slices.Equal(issues, tt.wantIssues) {
+				t.Errorf("Expected statuses %v to be kept, got %v", tt.wantIssues, issues)
+			}
+			if !slices.Equal(threads, tt.wantThreads) {
+				t.Errorf("Expected cleared threads %v to be kept, got %v", tt.wantThreads, threads)
+			}
+		})
+	}
+}
+
+func TestFileCacheService_LoadTouchesEntriesWithoutTimestamp(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeTestCache(t, tmpDir, []byte(`{"version":4,"pr_status":{"pr1":{"state":"merged","fetched_at":"2020-01-01T00:00:00Z"}}}`))
+
+	svc := NewFileCacheService(tmpDir, WithEviction(EvictionPolicy{MaxAge: day}))
+	cache, err := svc.Load()
+	if err != nil {
+		t.Fatalf("Failed to load cache: %v", err)
+	}
+	if err := svc.Save(cache); err != nil {
+		t.Fatalf("Failed to save cache: %v", err)
+	}
+
+	if status, ok := cache.PRStatus["pr1"]; !ok || status.UsedAt.IsZero() {
+		t.Errorf("Expected pr1 to be kept and timestamped, got %+v", status)
+	}
+}
+
+func TestFileCacheService_Maintenance(t *testing.T) {
+	svc := NewFileCacheService(t.TempDir())
+	cache, err := svc.Load()
+	if err != nil {
+		t.Fatalf("Failed to load cache: %v", err)
+	}
+	svc.SetPRStatus("https://api.github.com/repos/o/r/pulls/1", PRStatus{State: PRStateMerged})
+	svc.SetResponse("https://api.github.com/repos/o/r/pulls/1", CachedResponse{ETag: `"a"`})
+	svc.SetThreadDeleted("1", time.Now())
+
+	svc.GetPRStatus("https://api.github.com/repos/o/r/pulls/1")
+	svc.GetPRStatus("https://api.github.com/repos/o/r/pulls/2")
+	svc.GetPRStatus("https://api.github.com/repos/o/r/pulls/3")
+	svc.GetPRStatus("https://api.github.com/repos/o/r/pulls/4")
+
+	stats := svc.Stats()
+	if pr := stats.Kinds[CacheKindPR]; pr.Entries != 1 || pr.Hits != 1 || pr.Misses != 3 || pr.HitRate != 0.25 {
+		t.Errorf("Unexpected PR stats: %+v", pr)
+	}
+
+	entries, err := svc.Entries("")
+	if err != nil {
+		t.Fatalf("Failed to list entries: %v", err)
+	}
+	var kinds []string
+	for _, e := range entries {
+		kinds = append(kinds, e.Kind)
+	}
+	if want := []string{CacheKindPR, CacheKindThread, CacheKindResponse}; !slices.Equal(kinds, want) {
+		t.Errorf("Expected entries of kinds %v, got %v", want, kinds)
+	}
+	if _, err := svc.Entries("bogus"); err == nil {
+		t.Error("Expected error for an unknown kind, got nil")
+	}
+
+	n, err := svc.Forget("", "https://api.github.com/repos/o/r/pulls/1")
+	if err != nil || n != 2 {
+		t.Errorf("Expected to forget the PR status and response, got %d, %v", n, err)
+	}
+
+	var exported bytes.Buffer
+	if err := svc.Export(&exported); err != nil {
+		t.Fatalf("Failed to export cache: %v", err)
+	}
+	if err := svc.Clear(); err != nil {
+		t.Fatalf("Failed to clear cache: %v", err)
+	}
+	if len(cache.ThreadsDeleted) != 0 {
+		t.Fatalf("Expected Clear to empty the loaded cache, got %+v", cache)
+	}
+	if err := svc.Import(&exported); err != nil {
+		t.Fatalf("Failed to import cache: %v", err)
+	}
+	if _, ok := cache.ThreadsDeleted["1"]; !ok || len(cache.PRStatus) != 0 {
+		t.Errorf("Expected the exported cache back, got %+v", cache)
+	}
+
+	if err := svc.Import(strings.NewReader(`{"pr_status":{"pr1":true}}`)); err != nil {
+		t.Fatalf("Failed to import legacy cache: %v", err)
+	}
+	if cache.PRStatus["pr1"].State != PRStateMerged {
+		t.Errorf("Expected the legacy cache to be migrated on import, got %+v", cache.PRStatus)
 	}
 }
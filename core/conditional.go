@@ -0,0 +1,202 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+)
+
+// CachedResponse is a GET response kept so that it can be revalidated with a
+// conditional request. Only the notifications list keeps its body, to be
+// replayed when GitHub answers 304 Not Modified; pull requests and issues are
+// summarized by their cached status instead.
+type CachedResponse struct {
+	ETag         string          `json:"etag,omitempty"`
+	LastModified string          `json:"last_modified,omitempty"`
+	Link         string          `json:"link,omitempty"`
+	Body         json.RawMessage `json:"body,omitempty"`
+	// UsedAt is when the cache last stored or returned the response.
+	UsedAt time.Time `json:"used_at,omitzero"`
+}
+
+// NotModifiedError is returned for a 304 Not Modified on a resource whose
+// body is not cached, for the caller to answer from what it cached itself.
+type NotModifiedError struct {
+	// Key is the cached response holding the validators that were sent.
+	Key string
+}
+
+func (e *NotModifiedError) Error() string {
+	return "not modified: " + e.Key
+}
+
+type conditionalTransport struct {
+	base   http.RoundTripper
+	cache  CacheService
+	logger logr.Logger
+}
+
+// NewConditionalTransport wraps base so that GET requests send the ETag or
+// Last-Modified of the previous response from the cache. GitHub answers
+// unchanged resources with 304 Not Modified, which does not count against the
+// rate limit. The cached body of a notifications page is returned in its
+// place; for other resources the request fails with a NotModifiedError. The
+// cache must be loaded before the first request.
+func NewConditionalTransport(base http.RoundTripper, cache CacheService, logger logr.Logger) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &conditionalTransport{base: base, cache: cache, logger: logger}
+}
+
+func (t *conditionalTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet {
+		return t.base.RoundTrip(req)
+	}
+
+	key := conditionalKey(req.URL)
+	cached, hasCached := t.cache.GetResponse(key)
+	if hasCached {
+		req = req.Clone(req.Context())
+		// If-None-Match takes precedence, so If-Modified-Since is only sent
+		// for resources without an ETag.
+		if cached.ETag != "" {
+			req.Header.Set("If-None-Match", cached.ETag)
+		} else {
+			req.Header.Set("If-Modified-Since", cached.LastModified)
+		}
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified && hasCached:
+		if !keepsBody(req.URL) {
+			resp.Body.Close()
+			if cached.Body != nil {
+				// Caches written before bodies were limited to the
+				// notifications list shrink as they are revalidated.
+				cached.Body = nil
+				t.cache.SetResponse(key, cached)
+			}
+			t.logger.V(1).Info("Not modified", "url", req.URL.String())
+			return nil, &NotModifiedError{Key: key}
+		}
+		t.logger.V(1).Info("Not modified, using cached response", "url", req.URL.String())
+		return replay(resp, cached, req.URL), nil
+	case resp.StatusCode == http.StatusOK:
+		return t.store(key, req.URL, resp)
+	default:
+		return resp, nil
+	}
+}
+
+// store records the validators of a response, with its body for the
+// notifications list, and hands back a copy of it with its body still
+// readable.
+func (t *conditionalTransport) store(key string, u *url.URL, resp *http.Response) (*http.Response, error) {
+	etag, lastModified := resp.Header.Get("ETag"), resp.Header.Get("Last-Modified")
+	if etag == "" && lastModified == "" {
+		return resp, nil
+	}
+	if !keepsBody(u) {
+		t.cache.SetResponse(key, CachedResponse{ETag: etag, LastModified: lastModified})
+		return resp, nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, body); err != nil {
+		return resp, nil
+	}
+	t.cache.SetResponse(key, CachedResponse{
+		ETag:         etag,
+		LastModified: lastModified,
+		Link:         resp.Header.Get("Link"),
+		Body:         compact.Bytes(),
+	})
+	return resp, nil
+}
+
+// replay turns a 304 into the cached 200 response it confirmed. The cached
+// Link header was built for the request that stored the response, so its
+// targets are rebased onto the query of the current request u.
+func replay(resp *http.Response, cached CachedResponse, u *url.URL) *http.Response {
+	resp.Body.Close()
+
+	header := resp.Header.Clone()
+	header.Set("Content-Type", "application/json; charset=utf-8")
+	header.Del("Link")
+	if cached.Link != "" {
+		header.Set("Link", rebaseLink(cached.Link, u))
+	}
+
+	replayed := *resp
+	replayed.Status = "200 OK"
+	replayed.StatusCode = http.StatusOK
+	replayed.Header = header
+	replayed.Body = io.NopCloser(bytes.NewReader(cached.Body))
+	replayed.ContentLength = int64(len(cached.Body))
+	return &replayed
+}
+
+// rebaseLink gives the targets of a Link header the query of u with only
+// their own page, so that following a replayed link stays within the window
+// of the current request rather than the one the response was cached for.
+func rebaseLink(link string, u *url.URL) string {
+	parts := strings.Split(link, ",")
+	for i, part := range parts {
+		segments := strings.Split(part, ";")
+		target := strings.TrimSpace(segments[0])
+		if !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+			continue
+		}
+		next, err := url.Parse(strings.Trim(target, "<>"))
+		if err != nil {
+			continue
+		}
+		query := u.Query()
+		query.Del("page")
+		if page := next.Query().Get("page"); page != "" {
+			query.Set("page", page)
+		}
+		next.RawQuery = query.Encode()
+		segments[0] = "<" + next.String() + ">"
+		parts[i] = strings.Join(segments, ";")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// keepsBody reports whether the body of a response is cached to be replayed.
+// Only the notifications list is, because it is read directly; pull requests
+// and issues are summarized in their own cache entries.
+func keepsBody(u *url.URL) bool {
+	return strings.HasSuffix(u.Path, "/notifications")
+}
+
+// conditionalKey identifies a cached response. The since parameter of the
+// notifications list moves forward on every run, so it is left out; GitHub's
+// ETags are digests of the body, so a match still means the list is the same.
+// The links to further pages do carry since, which is why replay rebases them.
+func conditionalKey(u *url.URL) string {
+	key := *u
+	query := key.Query()
+	query.Del("since")
+	key.RawQuery = query.Encode()
+	return key.String()
+}
@@ -0,0 +1,172 @@
+package core
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/go-logr/logr/testr"
+)
+
+func TestConditionalTransport_ReplaysNotModified(t *testing.T) {
+	var calls atomic.Int32
+	server := cannedServer(t, &calls,
+		func(w http.ResponseWriter, r *http.Request) {
+			if got := r.Header.Get("If-None-Match"); got != "" {
+				t.Errorf("Expected no If-None-Match on the first request, got %q", got)
+			}
+			w.Header().Set("ETag", `W/"abc"`)
+			w.Header().Set("Link", `<https://api.github.com/notifications?all=true&page=2&since=2020-01-01T00%3A00%3A00Z>; rel="next"`)
+			_, _ = w.Write([]byte(`[ {"id": "1"} ]`))
+		},
+		func(w http.ResponseWriter, r *http.Request) {
+			if got := r.Header.Get("If-None-Match"); got != `W/"abc"` {
+				t.Errorf("Expected If-None-Match W/\"abc\", got %q", got)
+			}
+			w.WriteHeader(http.StatusNotModified)
+		},
+	)
+
+	cache := newMockCacheService()
+	client := &http.Client{Transport: NewConditionalTransport(http.DefaultTransport, cache, testr.New(t))}
+
+	for i, since := range []string{"2020-01-01T00:00:00Z", "2024-06-01T00:00:00Z"} {
+		resp, err := client.Get(server.URL + "/notifications?all=true&since=" + since)
+		if err != nil {
+			t.Fatalf("Request %d: unexpected error: %v", i+1, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("Request %d: expected 200, got %d", i+1, resp.StatusCode)
+		}
+		if !strings.Contains(string(body), `"id"`) {
+			t.Errorf("Request %d: expected the notifications body, got %q", i+1, body)
+		}
+
+		// The next page must stay within the window of this request, not
+		// the one the cached response was stored for.
+		next, err := url.Parse(nextPageURL(resp.Header.Get("Link")))
+		if err != nil || next.Path == "" {
+			t.Fatalf("Request %d: expected a next link, got %q", i+1, resp.Header.Get("Link"))
+		}
+		if got := next.Query().Get("since"); got != since {
+			t.Errorf("Request %d: expected the next link to carry since %s, got %s", i+1, since, got)
+		}
+		if got := next.Query().Get("page"); got != "2" {
+			t.Errorf("Request %d: expected the next link to page 2, got %q", i+1, got)
+		}
+	}
+
+	if calls.Load() != 2 {
+		t.Errorf("Expected 2 requests, got %d", calls.Load())
+	}
+}
+
+func TestConditionalTransport_IfModifiedSince(t *testing.T) {
+	const lastModified = "Mon, 01 Jan 2024 00:00:00 GMT"
+
+	var calls atomic.Int32
+	server := cannedServer(t, &calls,
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Last-Modified", lastModified)
+			_, _ = w.Write([]byte(`{"state":"open"}`))
+		},
+		func(w http.ResponseWriter, r *http.Request) {
+			if got := r.Header.Get("If-Modified-Since"); got != lastModified {
+				t.Errorf("Expected If-Modified-Since %q, got %q", lastModified, got)
+			}
+			w.Header().Set("Last-Modified", "Tue, 02 Jan 2024 00:00:00 GMT")
+			_, _ = w.Write([]byte(`{"state":"closed"}`))
+		},
+	)
+
+	cache := newMockCacheService()
+	client := &http.Client{Transport: NewConditionalTransport(http.DefaultTransport, cache, testr.New(t))}
+
+	for range 2 {
+		resp, err := client.Get(server.URL + "/repos/o/r/pulls/1")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		resp.Body.Close()
+	}
+
+	cached, ok := cache.GetResponse(server.URL + "/repos/o/r/pulls/1")
+	if !ok {
+		t.Fatal("Expected the response to be cached")
+	}
+	if cached.LastModified != "Tue, 02 Jan 2024 00:00:00 GMT" {
+		t.Errorf("Expected the new Last-Modified to replace the cached one, got %q", cached.LastModified)
+	}
+	if cached.Body != nil {
+		t.Errorf("Expected no body to be cached for a pull request, got %s", cached.Body)
+	}
+}
+
+func TestConditionalTransport_NotModifiedWithoutBody(t *testing.T) {
+	var calls atomic.Int32
+	server := cannedServer(t, &calls,
+		func(w http.ResponseWriter, r *http.Request) {
+			if got := r.Header.Get("If-None-Match"); got != `"abc"` {
+				t.Errorf("Expected If-None-Match \"abc\", got %q", got)
+			}
+			w.WriteHeader(http.StatusNotModified)
+		},
+	)
+
+	// A pull request cached with its body, as older versions did.
+	key := server.URL + "/repos/o/r/pulls/1"
+	cache := newMockCacheService()
+	cache.SetResponse(key, CachedResponse{ETag: `"abc"`, Body: []byte(`{"state":"open"}`)})
+	client := &http.Client{Transport: NewConditionalTransport(http.DefaultTransport, cache, testr.New(t))}
+
+	_, err := client.Get(key)
+	var notModified *NotModifiedError
+	if !errors.As(err, &notModified) || notModified.Key != key {
+		t.Fatalf("Expected a NotModifiedError for %s, got %v", key, err)
+	}
+	if cached, _ := cache.GetResponse(key); cached.ETag != `"abc"` || cached.Body != nil {
+		t.Errorf("Expected only the validators to be kept, got %+v", cached)
+	}
+}
+
+func TestConditionalTransport_SkipsUncacheableResponses(t *testing.T) {
+	var calls atomic.Int32
+	server := cannedServer(t, &calls,
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("ETag", `"abc"`)
+			_, _ = w.Write([]byte("ok"))
+		},
+	)
+
+	cache := newMockCacheService()
+	client := &http.Client{Transport: NewConditionalTransport(http.DefaultTransport, cache, testr.New(t))}
+
+	// Other methods pass through untouched.
+	resp, err := client.Post(server.URL, "application/json", strings.NewReader("{}"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	resp.Body.Close()
+
+	// Bodies that are not JSON are returned but not cached.
+	resp, err = client.Get(server.URL + "/notifications")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if string(body) != "ok" {
+		t.Errorf("Expected the body to still be readable, got %q", body)
+	}
+	if len(cache.cache.Responses) != 0 {
+		t.Errorf("Expected nothing cached, got %v", cache.cache.Responses)
+	}
+}
@@ -2,41 +2,117 @@ package core
 
 import (
 	"fmt"
+	"math"
 	"strconv"
+	"strings"
 	"time"
 )
 
-func parseDuration(since string) (time.Duration, error) {
+const (
+	day   = 24 * time.Hour
+	week  = 7 * day
+	month = 30 * day
+)
+
+// durationUnits are the units ParseDuration accepts. Months are always 30
+// days.
+var durationUnits = map[string]struct {
+	name string
+	size time.Duration
+}{
+	"mo": {"months", month},
+	"w":  {"weeks", week},
+	"d":  {"days", day},
+	"h":  {"hours", time.Hour},
+	"m":  {"minutes", time.Minute},
+	"s":  {"seconds", time.Second},
+}
+
+// ParseDuration parses a duration such as 7d, 36h, 2w, 1mo or a compound of
+// them like 1d12h.
+func ParseDuration(since string) (time.Duration, error) {
 	if len(since) < 2 {
 		return 0, fmt.Errorf("invalid duration format: %s", since)
 	}
+	if strings.HasPrefix(since, "-") {
+		return 0, fmt.Errorf("duration cannot be negative: %s", since)
+	}
 
-	// Check if there are multiple 'd' characters
-	dCount := 0
-	for _, c := range since {
-		if c == 'd' {
-			dCount++
+	var total time.Duration
+	for rest := since; rest != ""; {
+		numEnd := strings.IndexFunc(rest, isUnitLetter)
+		if numEnd == -1 {
+			return 0, fmt.Errorf("invalid duration format: %s (missing unit after %q)", since, rest)
 		}
+		if numEnd == 0 {
+			return 0, fmt.Errorf("invalid duration format: %s (missing number before %q)", since, rest)
+		}
+		unitEnd := strings.IndexFunc(rest[numEnd:], func(r rune) bool { return !isUnitLetter(r) })
+		if unitEnd == -1 {
+			unitEnd = len(rest)
+		} else {
+			unitEnd += numEnd
+		}
+
+		numStr, unitStr := rest[:numEnd], rest[numEnd:unitEnd]
+		rest = rest[unitEnd:]
+
+		unit, ok := durationUnits[unitStr]
+		if !ok {
+			return 0, fmt.Errorf("invalid duration unit: %s (supported units are mo, w, d, h, m and s)", unitStr)
+		}
+		if strings.Trim(numStr, "0123456789.") != "" {
+			return 0, fmt.Errorf("failed to parse %s: invalid number %q", unit.name, numStr)
+		}
+		n, err := strconv.ParseFloat(numStr, 64)
+		if err != nil {
+			return 0, fmt.Errorf("failed to parse %s: %w", unit.name, err)
+		}
+		part := n * float64(unit.size)
+		if part+float64(total) > math.MaxInt64 {
+			return 0, fmt.Errorf("duration too large: %s", since)
+		}
+		total += time.Duration(part)
 	}
-	if dCount != 1 {
-		return 0, fmt.Errorf("invalid duration unit: must have exactly one 'd'")
-	}
+	return total, nil
+}
 
-	unit := since[len(since)-1:]
-	if unit != "d" {
-		return 0, fmt.Errorf("invalid duration unit: %s (only 'd' is supported)", unit)
+func isUnitLetter(r rune) bool {
+	return strings.ContainsRune("mowdhs", r)
+}
+
+// ParseTime resolves a time given on the command line: an RFC 3339
+// timestamp, a YYYY-MM-DD date at midnight in now's location, or a duration
+// before now.
+func ParseTime(value string, now time.Time) (time.Time, error) {
+	if looksLikeDate(value) {
+		if t, err := time.Parse(time.RFC3339, value); err == nil {
+			return t, nil
+		}
+		t, err := time.ParseInLocation(time.DateOnly, value, now.Location())
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid date: %s (expected YYYY-MM-DD or RFC 3339)", value)
+		}
+		return t, nil
 	}
 
-	numStr := since[:len(since)-1]
-	days, err := strconv.Atoi(numStr)
+	d, err := ParseDuration(value)
 	if err != nil {
-		return 0, fmt.Errorf("failed to parse days: %w", err)
+		return time.Time{}, err
 	}
+	return now.Add(-d), nil
+}
 
-	if days < 0 {
-		return 0, fmt.Errorf("duration cannot be negative: %d", days)
+// looksLikeDate reports whether value starts like YYYY-, so that mistyped
+// dates get an error about dates rather than durations.
+func looksLikeDate(value string) bool {
+	if len(value) < 5 || value[4] != '-' {
+		return false
 	}
-
-	duration := fmt.Sprintf("%dh", 24*days)
-	return time.ParseDuration(duration)
+	for _, c := range value[:4] {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
 }
@@ -14,11 +14,20 @@ func TestParseDuration_ValidInput(t *testing.T) {
 		{"1d", 24 * time.Hour},
 		{"30d", 30 * 24 * time.Hour},
 		{"0d", 0},
+		{"12h", 12 * time.Hour},
+		{"36h", 36 * time.Hour},
+		{"1d12h", 36 * time.Hour},
+		{"90m", 90 * time.Minute},
+		{"1.5h", 90 * time.Minute},
+		{"2w", 14 * 24 * time.Hour},
+		{"1w2d", 9 * 24 * time.Hour},
+		{"1mo", 30 * 24 * time.Hour},
+		{"1h30m15s", time.Hour + 30*time.Minute + 15*time.Second},
 	}
 
 	for _, test := range tests {
 		t.Run(test.input, func(t *testing.T) {
-			result, err := parseDuration(test.input)
+			result, err := ParseDuration(test.input)
 			if err != nil {
 				t.Fatalf("Unexpected error: %v", err)
 			}
@@ -37,7 +46,14 @@ func TestParseDuration_InvalidInput(t *testing.T) {
 		{"7", "invalid duration format"},
 		{"d", "invalid duration format"},
 		{"7dd", "invalid duration unit"},
-		{"7h", "invalid duration unit"},
+		{"7x", "invalid duration format"},
+		{"soon", "invalid duration format"},
+		{"1d12", "invalid duration format"},
+		{"7y", "invalid duration format"},
+		{"7mos", "invalid duration unit"},
+		{"1d2x3h", "failed to parse hours"},
+		{"1..5h", "failed to parse hours"},
+		{"9999999999w", "duration too large"},
 		{"", "invalid duration format"},
 		{"-7d", "duration cannot be negative"},
 		{"ad", "failed to parse days"},
@@ -45,7 +61,7 @@ func TestParseDuration_InvalidInput(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.input, func(t *testing.T) {
-			_, err := parseDuration(tt.input)
+			_, err := ParseDuration(tt.input)
 			if err == nil {
 				t.Errorf("Expected error for input %q, got nil", tt.input)
 			}
@@ -56,6 +72,59 @@ func TestParseDuration_InvalidInput(t *testing.T) {
 	}
 }
 
+func TestParseTime(t *testing.T) {
+	loc := time.FixedZone("UTC+2", 2*60*60)
+	now := time.Date(2024, 6, 15, 12, 0, 0, 0, loc)
+
+	tests := []struct {
+		input string
+		want  time.Time
+	}{
+		{"7d", now.Add(-7 * 24 * time.Hour)},
+		{"1d12h", now.Add(-36 * time.Hour)},
+		{"2024-06-01", time.Date(2024, 6, 1, 0, 0, 0, 0, loc)},
+		{"2024-06-01T08:30:00Z", time.Date(2024, 6, 1, 8, 30, 0, 0, time.UTC)},
+		{"2024-06-01T08:30:00-05:00", time.Date(2024, 6, 1, 13, 30, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseTime(tt.input, now)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestParseTime_InvalidInput(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"2024-13-01", "invalid date"},
+		{"2024-06-01 08:30", "invalid date"},
+		{"2024-06", "invalid date"},
+		{"1y", "invalid duration format"},
+		{"-1d", "duration cannot be negative"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			_, err := ParseTime(tt.input, time.Now())
+			if err == nil {
+				t.Fatalf("Expected error for input %q, got nil", tt.input)
+			}
+			if !contains(err.Error(), tt.want) {
+				t.Errorf("Expected error containing %q, got %q", tt.want, err.Error())
+			}
+		})
+	}
+}
+
 func contains(s, substr string) bool {
 	return s != "" && substr != "" && s != substr && len(s) > len(substr) && s[:len(substr)] == substr
 }
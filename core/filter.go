@@ -0,0 +1,63 @@
+package core
+
+import (
+	"fmt"
+	"path"
+)
+
+// Filter narrows a sweep to some notifications before any rule is evaluated,
+// so filtered out threads cost no API calls. Empty include lists include
+// everything, and excludes take precedence over includes.
+type Filter struct {
+	// Repositories and ExcludeRepositories are owner/name globs such as
+	// myorg/*.
+	Repositories        []string
+	ExcludeRepositories []string
+	Owners              []string
+	ExcludeOwners       []string
+	Reasons             []string
+	ExcludeReasons      []string
+	Types               []string
+	ExcludeTypes        []string
+}
+
+// Validate checks that every repository pattern is a valid glob.
+func (f Filter) Validate() error {
+	for _, patterns := range [][]string{f.Repositories, f.ExcludeRepositories} {
+		for _, pattern := range patterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("invalid repository pattern %q: %w", pattern, err)
+			}
+		}
+	}
+	return nil
+}
+
+// IsEmpty reports whether the filter includes every notification.
+func (f Filter) IsEmpty() bool {
+	for _, list := range [][]string{
+		f.Repositories, f.ExcludeRepositories, f.Owners, f.ExcludeOwners,
+		f.Reasons, f.ExcludeReasons, f.Types, f.ExcludeTypes,
+	} {
+		if len(list) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Includes reports whether the notification passes the filter.
+func (f Filter) Includes(n Notification) bool {
+	repo, owner := n.Repository.FullName, n.Repository.Owner.Login
+	return includes(f.Repositories, f.ExcludeRepositories, repo, matchesGlob) &&
+		includes(f.Owners, f.ExcludeOwners, owner, matchesFold) &&
+		includes(f.Reasons, f.ExcludeReasons, n.Reason, matchesFold) &&
+		includes(f.Types, f.ExcludeTypes, n.Subject.Type, matchesFold)
+}
+
+func includes(include, exclude []string, value string, match func([]string, string) bool) bool {
+	if len(exclude) > 0 && match(exclude, value) {
+		return false
+	}
+	return len(include) == 0 || match(include, value)
+}
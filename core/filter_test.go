@@ -0,0 +1,48 @@
+package core
+
+import "testing"
+
+func TestFilter_Includes(t *testing.T) {
+	n := Notification{
+		Reason:     "review_requested",
+		Subject:    Subject{Type: "PullRequest"},
+		Repository: Repository{FullName: "MyOrg/app", Owner: User{Login: "MyOrg"}},
+	}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   bool
+	}{
+		{"empty filter", Filter{}, true},
+		{"repository glob", Filter{Repositories: []string{"myorg/*"}}, true},
+		{"other repository", Filter{Repositories: []string{"other/*"}}, false},
+		{"excluded repository", Filter{ExcludeRepositories: []string{"myorg/app"}}, false},
+		{"exclude wins over include", Filter{Repositories: []string{"myorg/*"}, ExcludeRepositories: []string{"*/app"}}, false},
+		{"owner", Filter{Owners: []string{"myorg", "other"}}, true},
+		{"excluded owner", Filter{ExcludeOwners: []string{"myorg"}}, false},
+		{"reason", Filter{Reasons: []string{"mention", "review_requested"}}, true},
+		{"other reason", Filter{Reasons: []string{"ci_activity"}}, false},
+		{"excluded reason", Filter{ExcludeReasons: []string{"review_requested"}}, false},
+		{"type", Filter{Types: []string{"pullrequest"}}, true},
+		{"excluded type", Filter{ExcludeTypes: []string{"PullRequest"}}, false},
+		{"every condition must pass", Filter{Owners: []string{"myorg"}, Types: []string{"Issue"}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.Includes(n); got != tt.want {
+				t.Errorf("Includes() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilter_Validate(t *testing.T) {
+	if err := (Filter{Repositories: []string{"myorg/*"}}).Validate(); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if err := (Filter{ExcludeRepositories: []string{"myorg/["}}).Validate(); err == nil {
+		t.Error("Expected error for an invalid pattern, got nil")
+	}
+}
@@ -1,40 +1,204 @@
 package core
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
+	"strconv"
 	"strings"
 	"time"
 )
 
+const (
+	defaultPerPage  = 50
+	defaultMaxPages = 20
+)
+
+// ErrTruncated is returned along with the notifications fetched so far when
+// the page limit stops a fetch before the last page.
+var ErrTruncated = errors.New("stopped before the last page of notifications")
+
 type GithubClient interface {
 	Get(url string, response interface{}) error
 	Delete(url string, response interface{}) error
+	Patch(url string, body io.Reader, response interface{}) error
+	Put(url string, body io.Reader, response interface{}) error
+	Request(method string, url string, body io.Reader) (*http.Response, error)
 }
 
 type githubRepository struct {
-	client GithubClient
+	client        GithubClient
+	perPage       int
+	maxPages      int
+	before        string
+	unreadOnly    bool
+	participating bool
+	pollInterval  time.Duration
+	now           func() time.Time
+}
+
+type RepositoryOption func(*githubRepository)
+
+// WithPerPage sets how many notifications are requested per page.
+func WithPerPage(n int) RepositoryOption {
+	return func(r *githubRepository) {
+		if n > 0 {
+			r.perPage = n
+		}
+	}
+}
+
+// WithMaxPages caps how many pages are followed in a single fetch.
+func WithMaxPages(n int) RepositoryOption {
+	return func(r *githubRepository) {
+		if n > 0 {
+			r.maxPages = n
+		}
+	}
 }
 
-func NewGithubRepository(client GithubClient) NotificationRepository {
-	return &githubRepository{client: client}
+// WithBefore limits fetches to notifications updated before a date, or
+// before a duration ago. Like since, a duration is resolved on every fetch.
+func WithBefore(before string) RepositoryOption {
+	return func(r *githubRepository) {
+		r.before = before
+	}
+}
+
+// WithUnreadOnly fetches only unread notifications instead of every
+// notification in the window.
+func WithUnreadOnly(unreadOnly bool) RepositoryOption {
+	return func(r *githubRepository) {
+		r.unreadOnly = unreadOnly
+	}
+}
+
+// WithParticipating fetches only notifications for threads the user is
+// directly participating in or mentioned in.
+func WithParticipating(participating bool) RepositoryOption {
+	return func(r *githubRepository) {
+		r.participating = participating
+	}
+}
+
+func NewGithubRepository(client GithubClient, opts ...RepositoryOption) NotificationRepository {
+	r := &githubRepository{
+		client:   client,
+		perPage:  defaultPerPage,
+		maxPages: defaultMaxPages,
+		now:      time.Now,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
 }
 
 func (r *githubRepository) Delete(id string) error {
 	return r.client.Delete("notifications/threads/"+id, nil)
 }
 
+func (r *githubRepository) MarkRead(id string) error {
+	return r.client.Patch("notifications/threads/"+id, nil, nil)
+}
+
+func (r *githubRepository) Unsubscribe(id string) error {
+	return r.client.Delete("notifications/threads/"+id+"/subscription", nil)
+}
+
+// Ignore keeps the thread subscription but mutes it, so later activity does
+// not notify even after commenting.
+func (r *githubRepository) Ignore(id string) error {
+	body := strings.NewReader(`{"ignored":true}`)
+	return r.client.Put("notifications/threads/"+id+"/subscription", body, nil)
+}
+
 func (r *githubRepository) GetByTimePeriod(since string) ([]Notification, error) {
-	sinceTime, err := parseDuration(since)
+	now := r.now()
+	sinceTime, err := ParseTime(since, now)
 	if err != nil {
 		return nil, err
 	}
 
-	sinceDate := time.Now().Add(-sinceTime).Format(time.RFC3339)
-	url := fmt.Sprintf("notifications?all=true&since=%s", sinceDate)
+	url := fmt.Sprintf("notifications?all=%t&since=%s&per_page=%d", !r.unreadOnly, sinceTime.UTC().Format(time.RFC3339), r.perPage)
+	if r.participating {
+		url += "&participating=true"
+	}
+	if r.before != "" {
+		beforeTime, err := ParseTime(r.before, now)
+		if err != nil {
+			return nil, fmt.Errorf("invalid before: %w", err)
+		}
+		if !beforeTime.After(sinceTime) {
+			return nil, fmt.Errorf("before (%s) must be later than since (%s)",
+				beforeTime.Format(time.RFC3339), sinceTime.Format(time.RFC3339))
+		}
+		url += "&before=" + beforeTime.UTC().Format(time.RFC3339)
+	}
 
 	var notifications []Notification
-	err = r.client.Get(url, &notifications)
-	return notifications, err
+	for page := 0; url != "" && page < r.maxPages; page++ {
+		var batch []Notification
+		url, err = r.getPage(url, &batch)
+		if err != nil {
+			return notifications, err
+		}
+		notifications = append(notifications, batch...)
+	}
+	if url != "" {
+		return notifications, fmt.Errorf("%w after %d pages", ErrTruncated, r.maxPages)
+	}
+	return notifications, nil
+}
+
+func (r *githubRepository) getPage(url string, response interface{}) (string, error) {
+	resp, err := r.client.Request(http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(response); err != nil {
+		return "", fmt.Errorf("failed to decode %s: %w", url, err)
+	}
+	if seconds, err := strconv.Atoi(resp.Header.Get("X-Poll-Interval")); err == nil {
+		r.pollInterval = time.Duration(seconds) * time.Second
+	}
+	return nextPageURL(resp.Header.Get("Link")), nil
+}
+
+// Narrowed reports whether fetches skip notifications in the window, because
+// only unread or participating threads are requested.
+func (r *githubRepository) Narrowed() bool {
+	return r.unreadOnly || r.participating
+}
+
+// PollInterval returns the X-Poll-Interval of the last notifications fetch,
+// or zero before the first one.
+func (r *githubRepository) PollInterval() time.Duration {
+	return r.pollInterval
+}
+
+// nextPageURL extracts the rel="next" target from a Link header.
+func nextPageURL(link string) string {
+	for _, part := range strings.Split(link, ",") {
+		segments := strings.Split(part, ";")
+		if len(segments) < 2 {
+			continue
+		}
+		target := strings.TrimSpace(segments[0])
+		if !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+			continue
+		}
+		for _, param := range segments[1:] {
+			if strings.TrimSpace(param) == `rel="next"` {
+				return strings.Trim(target, "<>")
+			}
+		}
+	}
+	return ""
 }
 
 func formatGithubURL(url string) string {
@@ -1,12 +1,22 @@
 package core
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
 	"testing"
+	"time"
 )
 
 type mockGithubClient struct {
-	getFunc    func(url string, response interface{}) error
-	deleteFunc func(url string, response interface{}) error
+	getFunc     func(url string, response interface{}) error
+	deleteFunc  func(url string, response interface{}) error
+	patchFunc   func(url string, body io.Reader, response interface{}) error
+	putFunc     func(url string, body io.Reader, response interface{}) error
+	requestFunc func(method string, url string, body io.Reader) (*http.Response, error)
 }
 
 func (m *mockGithubClient) Get(url string, response interface{}) error {
@@ -17,6 +27,50 @@ func (m *mockGithubClient) Delete(url string, response interface{}) error {
 	return m.deleteFunc(url, response)
 }
 
+func (m *mockGithubClient) Patch(url string, body io.Reader, response interface{}) error {
+	return m.patchFunc(url, body, response)
+}
+
+func (m *mockGithubClient) Put(url string, body io.Reader, response interface{}) error {
+	return m.putFunc(url, body, response)
+}
+
+func (m *mockGithubClient) Request(method string, url string, body io.Reader) (*http.Response, error) {
+	return m.requestFunc(method, url, body)
+}
+
+func jsonResponse(t *testing.T, v interface{}, header http.Header) *http.Response {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("Failed to marshal response: %v", err)
+	}
+	if header == nil {
+		header = http.Header{}
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     header,
+		Body:       io.NopCloser(strings.NewReader(string(data))),
+	}
+}
+
+// pagedClient serves pages in order, linking each one to the next.
+func pagedClient(t *testing.T, pages [][]Notification, requested *[]string) *mockGithubClient {
+	return &mockGithubClient{
+		requestFunc: func(method string, url string, body io.Reader) (*http.Response, error) {
+			*requested = append(*requested, url)
+			page := len(*requested)
+			header := http.Header{}
+			if page < len(pages) {
+				next := fmt.Sprintf("https://api.github.com/notifications?page=%d", page+1)
+				header.Set("Link", fmt.Sprintf(`<%s>; rel="next", <https://api.github.com/notifications?page=%d>; rel="last"`, next, len(pages)))
+			}
+			return jsonResponse(t, pages[page-1], header), nil
+		},
+	}
+}
+
 func TestGithubRepository_GetByTimePeriod(t *testing.T) {
 	notifications := []Notification{
 		{ID: "1", Subject: Subject{Title: "PR 1", Type: "PullRequest"}},
@@ -24,10 +78,11 @@ func TestGithubRepository_GetByTimePeriod(t *testing.T) {
 	}
 
 	client := &mockGithubClient{
-		getFunc: func(url string, response interface{}) error {
-			resp := response.(*[]Notification)
-			*resp = notifications
-			return nil
+		requestFunc: func(method string, url string, body io.Reader) (*http.Response, error) {
+			if method != http.MethodGet {
+				t.Errorf("Expected GET, got %s", method)
+			}
+			return jsonResponse(t, notifications, nil), nil
 		},
 	}
 
@@ -42,6 +97,108 @@ func TestGithubRepository_GetByTimePeriod(t *testing.T) {
 	}
 }
 
+func TestGithubRepository_GetByTimePeriod_FollowsPagination(t *testing.T) {
+	pages := [][]Notification{
+		{{ID: "1"}, {ID: "2"}},
+		{{ID: "3"}, {ID: "4"}},
+		{{ID: "5"}},
+	}
+
+	var requested []string
+	repo := NewGithubRepository(pagedClient(t, pages, &requested), WithPerPage(2))
+	result, err := repo.GetByTimePeriod("30d")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(result) != 5 {
+		t.Errorf("Expected 5 notifications, got %d", len(result))
+	}
+	if len(requested) != 3 {
+		t.Fatalf("Expected 3 requests, got %d", len(requested))
+	}
+	if !strings.Contains(requested[0], "per_page=2") {
+		t.Errorf("Expected first request to set per_page=2, got %s", requested[0])
+	}
+	if requested[2] != "https://api.github.com/notifications?page=3" {
+		t.Errorf("Expected last request to follow next link, got %s", requested[2])
+	}
+}
+
+func TestGithubRepository_GetByTimePeriod_MaxPages(t *testing.T) {
+	pages := [][]Notification{
+		{{ID: "1"}},
+		{{ID: "2"}},
+		{{ID: "3"}},
+	}
+
+	var requested []string
+	repo := NewGithubRepository(pagedClient(t, pages, &requested), WithMaxPages(2))
+	result, err := repo.GetByTimePeriod("7d")
+	if !errors.Is(err, ErrTruncated) {
+		t.Fatalf("Expected ErrTruncated, got %v", err)
+	}
+
+	if len(result) != 2 {
+		t.Errorf("Expected 2 notifications, got %d", len(result))
+	}
+	if len(requested) != 2 {
+		t.Errorf("Expected 2 requests, got %d", len(requested))
+	}
+}
+
+func TestGithubRepository_GetByTimePeriod_PageError(t *testing.T) {
+	calls := 0
+	client := &mockGithubClient{
+		requestFunc: func(method string, url string, body io.Reader) (*http.Response, error) {
+			calls++
+			if calls == 2 {
+				return nil, fmt.Errorf("boom")
+			}
+			header := http.Header{}
+			header.Set("Link", `<https://api.github.com/notifications?page=2>; rel="next"`)
+			return jsonResponse(t, []Notification{{ID: "1"}}, header), nil
+		},
+	}
+
+	repo := NewGithubRepository(client)
+	result, err := repo.GetByTimePeriod("7d")
+	if err == nil {
+		t.Fatal("Expected error, got nil")
+	}
+	if len(result) != 1 {
+		t.Errorf("Expected the first page to be returned, got %d notifications", len(result))
+	}
+}
+
+func TestNextPageURL(t *testing.T) {
+	tests := []struct {
+		name string
+		link string
+		want string
+	}{
+		{"empty", "", ""},
+		{
+			"next and last",
+			`<https://api.github.com/notifications?page=2>; rel="next", <https://api.github.com/notifications?page=5>; rel="last"`,
+			"https://api.github.com/notifications?page=2",
+		},
+		{
+			"last page",
+			`<https://api.github.com/notifications?page=1>; rel="prev", <https://api.github.com/notifications?page=1>; rel="first"`,
+			"",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := nextPageURL(tt.link); got != tt.want {
+				t.Errorf("nextPageURL() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestGithubRepository_Delete(t *testing.T) {
 	called := false
 	client := &mockGithubClient{
@@ -63,3 +220,226 @@ func TestGithubRepository_Delete(t *testing.T) {
 		t.Error("Delete was not called")
 	}
 }
+
+func TestGithubRepository_MarkRead(t *testing.T) {
+	called := false
+	client := &mockGithubClient{
+		patchFunc: func(url string, body io.Reader, response interface{}) error {
+			called = true
+			if url != "notifications/threads/123" {
+				t.Errorf("Expected url notifications/threads/123, got %s", url)
+			}
+			return nil
+		},
+	}
+
+	if err := NewGithubRepository(client).MarkRead("123"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !called {
+		t.Error("Patch was not called")
+	}
+}
+
+func TestGithubRepository_Unsubscribe(t *testing.T) {
+	called := false
+	client := &mockGithubClient{
+		deleteFunc: func(url string, response interface{}) error {
+			called = true
+			if url != "notifications/threads/123/subscription" {
+				t.Errorf("Expected url notifications/threads/123/subscription, got %s", url)
+			}
+			return nil
+		},
+	}
+
+	if err := NewGithubRepository(client).Unsubscribe("123"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !called {
+		t.Error("Delete was not called")
+	}
+}
+
+func TestGithubRepository_Ignore(t *testing.T) {
+	called := false
+	client := &mockGithubClient{
+		putFunc: func(url string, body io.Reader, response interface{}) error {
+			called = true
+			if url != "notifications/threads/123/subscription" {
+				t.Errorf("Expected url notifications/threads/123/subscription, got %s", url)
+			}
+			var payload map[string]bool
+			if err := json.NewDecoder(body).Decode(&payload); err != nil {
+				t.Fatalf("Failed to decode body: %v", err)
+			}
+			if !payload["ignored"] {
+				t.Errorf("Expected ignored to be true, got %v", payload)
+			}
+			return nil
+		},
+	}
+
+	if err := NewGithubRepository(client).Ignore("123"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !called {
+		t.Error("Put was not called")
+	}
+}
+
+func TestGithubRepository_PollInterval(t *testing.T) {
+	client := &mockGithubClient{
+		requestFunc: func(method string, url string, body io.Reader) (*http.Response, error) {
+			header := http.Header{}
+			header.Set("X-Poll-Interval", "120")
+			return jsonResponse(t, []Notification{}, header), nil
+		},
+	}
+
+	repo := NewGithubRepository(client).(PollingRepository)
+	if got := repo.PollInterval(); got != 0 {
+		t.Errorf("Expected no poll interval before fetching, got %v", got)
+	}
+	if _, err := repo.GetByTimePeriod("7d"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := repo.PollInterval(); got != 2*time.Minute {
+		t.Errorf("Expected poll interval of 2m, got %v", got)
+	}
+}
+
+func TestGithubRepository_GetByTimePeriod_Window(t *testing.T) {
+	var requested []string
+	repo := NewGithubRepository(pagedClient(t, [][]Notification{{}}, &requested), WithBefore("2024-01-31T00:00:00Z"))
+
+	if _, err := repo.GetByTimePeriod("2024-01-01"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.Contains(requested[0], "&before=2024-01-31T00:00:00Z") {
+		t.Errorf("Expected the request to set before, got %s", requested[0])
+	}
+
+	if _, err := repo.GetByTimePeriod("2024-02-01"); err == nil {
+		t.Error("Expected an error for a window that ends before it starts, got nil")
+	}
+}
+
+func TestGithubRepository_GetByTimePeriod_RelativeBefore(t *testing.T) {
+	var requested []string
+	client := &mockGithubClient{
+		requestFunc: func(method string, url string, body io.Reader) (*http.Response, error) {
+			requested = append(requested, url)
+			return jsonResponse(t, []Notification{}, nil), nil
+		},
+	}
+	repo := NewGithubRepository(client, WithBefore("1d"))
+	gr := repo.(*githubRepository)
+
+	for _, now := range []time.Time{
+		time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC),
+		time.Date(2024, 1, 11, 0, 0, 0, 0, time.UTC),
+	} {
+		gr.now = func() time.Time { return now }
+		if _, err := repo.GetByTimePeriod("7d"); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	for i, want := range []string{"&before=2024-01-09T00:00:00Z", "&before=2024-01-10T00:00:00Z"} {
+		if !strings.Contains(requested[i], want) {
+			t.Errorf("Request %d: expected %s, got %s", i+1, want, requested[i])
+		}
+	}
+}
+
+func TestGithubRepository_DecodesFullPayload(t *testing.T) {
+	payload := `[{
+		"id": "1",
+		"reason": "review_requested",
+		"unread": true,
+		"updated_at": "2024-06-01T10:00:00Z",
+		"last_read_at": "2024-05-31T09:00:00Z",
+		"url": "https://api.github.com/notifications/threads/1",
+		"subscription_url": "https://api.github.com/notifications/threads/1/subscription",
+		"subject": {
+			"title": "Add feature",
+			"type": "PullRequest",
+			"url": "https://api.github.com/repos/octo/app/pulls/2",
+			"latest_comment_url": "https://api.github.com/repos/octo/app/issues/comments/3"
+		},
+		"repository": {
+			"full_name": "octo/app",
+			"owner": {"login": "octo"},
+			"private": true,
+			"archived": true,
+			"fork": true
+		}
+	}]`
+
+	client := &mockGithubClient{
+		requestFunc: func(method string, url string, body io.Reader) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{},
+				Body:       io.NopCloser(strings.NewReader(payload)),
+			}, nil
+		},
+	}
+
+	result, err := NewGithubRepository(client).GetByTimePeriod("7d")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(result) != 1 {
+		t.Fatalf("Expected 1 notification, got %d", len(result))
+	}
+
+	n := result[0]
+	lastRead := time.Date(2024, 5, 31, 9, 0, 0, 0, time.UTC)
+	if n.Reason != "review_requested" || !n.Unread || n.LastReadAt == nil || !n.LastReadAt.Equal(lastRead) {
+		t.Errorf("Unexpected thread fields: %+v", n)
+	}
+	if n.SubscriptionURL != "https://api.github.com/notifications/threads/1/subscription" {
+		t.Errorf("Unexpected subscription URL: %q", n.SubscriptionURL)
+	}
+	if n.Subject.LatestCommentURL != "https://api.github.com/repos/octo/app/issues/comments/3" {
+		t.Errorf("Unexpected latest comment URL: %q", n.Subject.LatestCommentURL)
+	}
+	if r := n.Repository; r.FullName != "octo/app" || r.Owner.Login != "octo" || !r.Private || !r.Archived || !r.Fork {
+		t.Errorf("Unexpected repository: %+v", r)
+	}
+}
+
+func TestGithubRepository_GetByTimePeriod_Query(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []RepositoryOption
+		want    []string
+		notWant []string
+	}{
+		{"default", nil, []string{"all=true"}, []string{"participating"}},
+		{"unread only", []RepositoryOption{WithUnreadOnly(true)}, []string{"all=false"}, nil},
+		{"participating", []RepositoryOption{WithParticipating(true)}, []string{"all=true", "&participating=true"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requested []string
+			repo := NewGithubRepository(pagedClient(t, [][]Notification{{}}, &requested), tt.opts...)
+			if _, err := repo.GetByTimePeriod("7d"); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(requested[0], want) {
+					t.Errorf("Expected %s in %s", want, requested[0])
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(requested[0], notWant) {
+					t.Errorf("Expected no %s in %s", notWant, requested[0])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxGraphQLBatch is the number of pull requests resolved per query, which
+// keeps each query well within GitHub's node limits.
+const maxGraphQLBatch = 100
+
+type GraphQLClient interface {
+	Do(query string, variables map[string]interface{}, response interface{}) error
+}
+
+// BatchPRService is a PRService that can also resolve many pull requests in
+// a single round trip.
+type BatchPRService interface {
+	PRService
+	// GetPRStatuses returns the statuses it could resolve, keyed by URL. On
+	// error the map still holds every status that could be resolved.
+	GetPRStatuses(urls []string) (map[string]PRStatus, error)
+}
+
+type graphqlPRService struct {
+	client   GraphQLClient
+	fallback PRService
+}
+
+// NewGraphQLPRService resolves pull requests in batches through GraphQL and
+// uses fallback for single lookups.
+func NewGraphQLPRService(client GraphQLClient, fallback PRService) BatchPRService {
+	return &graphqlPRService{client: client, fallback: fallback}
+}
+
+func (s *graphqlPRService) GetPRStatus(url string) (PRStatus, error) {
+	return s.fallback.GetPRStatus(url)
+}
+
+func (s *graphqlPRService) GetPRStatuses(urls []string) (map[string]PRStatus, error) {
+	statuses := make(map[string]PRStatus, len(urls))
+	var errs []error
+	for start := 0; start < len(urls); start += maxGraphQLBatch {
+		end := min(start+maxGraphQLBatch, len(urls))
+		if err := s.resolve(urls[start:end], statuses); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return statuses, errors.Join(errs...)
+}
+
+type graphqlPullRequest struct {
+	State    string     `json:"state"`
+	IsDraft  bool       `json:"isDraft"`
+	Merged   bool       `json:"merged"`
+	MergedAt *time.Time `json:"mergedAt"`
+	ClosedAt *time.Time `json:"closedAt"`
+	Author   *User      `json:"author"`
+}
+
+func (s *graphqlPRService) resolve(urls []string, statuses map[string]PRStatus) error {
+	var query strings.Builder
+	var params []string
+	variables := make(map[string]interface{})
+	aliases := make(map[string]string)
+
+	for i, u := range urls {
+		// URLs that cannot be parsed are left for the fallback to look up.
+		owner, repo, number, err := parsePullRequestURL(u)
+		if err != nil {
+			continue
+		}
+
+		alias := fmt.Sprintf("pr%d", i)
+		aliases[alias] = u
+		variables[alias+"Owner"] = owner
+		variables[alias+"Repo"] = repo
+		variables[alias+"Number"] = number
+		params = append(params, fmt.Sprintf("$%[1]sOwner: String!, $%[1]sRepo: String!, $%[1]sNumber: Int!", alias))
+		fmt.Fprintf(&query, "  %[1]s: repository(owner: $%[1]sOwner, name: $%[1]sRepo) {\n"+
+			"    pullRequest(number: $%[1]sNumber) { state isDraft merged mergedAt closedAt author { login } }\n"+
+			"  }\n", alias)
+	}
+
+	if len(params) == 0 {
+		return nil
+	}
+	q := fmt.Sprintf("query(%s) {\n%s}", strings.Join(params, ", "), query.String())
+
+	var response map[string]*struct {
+		PullRequest *graphqlPullRequest `json:"pullRequest"`
+	}
+	err := s.client.Do(q, variables, &response)
+
+	// GraphQL reports missing repositories as errors alongside the data it
+	// could resolve, so keep whatever came back before returning the error.
+	for alias, repo := range response {
+		if repo == nil || repo.PullRequest == nil {
+			continue
+		}
+		pr := repo.PullRequest
+		status := newPRStatus(PullRequest{
+			State:    strings.ToLower(pr.State),
+			Draft:    pr.IsDraft,
+			Merged:   pr.Merged,
+			MergedAt: pr.MergedAt,
+			ClosedAt: pr.ClosedAt,
+		})
+		if pr.Author != nil {
+			status.Author = pr.Author.Login
+		}
+		statuses[aliases[alias]] = status
+	}
+	return err
+}
+
+// parsePullRequestURL splits an API URL such as
+// https://api.github.com/repos/owner/repo/pulls/1 into its parts.
+func parsePullRequestURL(rawURL string) (string, string, int, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", "", 0, err
+	}
+
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	// GitHub Enterprise serves the API under /api/v3.
+	if len(parts) > 2 && parts[0] == "api" {
+		parts = parts[2:]
+	}
+	if len(parts) != 5 || parts[0] != "repos" || parts[3] != "pulls" {
+		return "", "", 0, fmt.Errorf("not a pull request URL: %s", rawURL)
+	}
+
+	number, err := strconv.Atoi(parts[4])
+	if err != nil {
+		return "", "", 0, fmt.Errorf("invalid pull request number in %s: %w", rawURL, err)
+	}
+	return parts[1], parts[2], number, nil
+}
@@ -0,0 +1,109 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+type mockGraphQLClient struct {
+	doFunc func(query string, variables map[string]interface{}, response interface{}) error
+}
+
+func (m *mockGraphQLClient) Do(query string, variables map[string]interface{}, response interface{}) error {
+	return m.doFunc(query, variables, response)
+}
+
+func TestParsePullRequestURL(t *testing.T) {
+	tests := []struct {
+		url        string
+		wantOwner  string
+		wantRepo   string
+		wantNumber int
+		wantErr    bool
+	}{
+		{url: "https://api.github.com/repos/owner/repo/pulls/42", wantOwner: "owner", wantRepo: "repo", wantNumber: 42},
+		{url: "https://ghe.example.com/api/v3/repos/owner/repo/pulls/7", wantOwner: "owner", wantRepo: "repo", wantNumber: 7},
+		{url: "https://api.github.com/repos/owner/repo/issues/42", wantErr: true},
+		{url: "https://api.github.com/repos/owner/repo/pulls/abc", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			owner, repo, number, err := parsePullRequestURL(tt.url)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parsePullRequestURL() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && (owner != tt.wantOwner || repo != tt.wantRepo || number != tt.wantNumber) {
+				t.Errorf("parsePullRequestURL() = %s/%s#%d, want %s/%s#%d", owner, repo, number, tt.wantOwner, tt.wantRepo, tt.wantNumber)
+			}
+		})
+	}
+}
+
+func TestGraphQLPRService_GetPRStatuses(t *testing.T) {
+	client := &mockGraphQLClient{
+		doFunc: func(query string, variables map[string]interface{}, response interface{}) error {
+			if variables["pr0Owner"] != "owner" || variables["pr0Repo"] != "repo" || variables["pr0Number"] != 1 {
+				t.Errorf("Unexpected variables for pr0: %v", variables)
+			}
+			if !strings.Contains(query, "pr2: repository(owner: $pr2Owner, name: $pr2Repo)") {
+				t.Errorf("Expected query to alias every PR, got %s", query)
+			}
+			data := `{
+				"pr0": {"pullRequest": {"state": "MERGED", "merged": true, "mergedAt": "2024-01-02T03:04:05Z", "author": {"login": "octocat"}}},
+				"pr1": {"pullRequest": {"state": "OPEN", "isDraft": true}},
+				"pr2": null
+			}`
+			if err := json.Unmarshal([]byte(data), response); err != nil {
+				t.Fatalf("Failed to decode canned response: %v", err)
+			}
+			return errors.New("Could not resolve to a Repository")
+		},
+	}
+
+	service := NewGraphQLPRService(client, &mockPRService{})
+	statuses, err := service.GetPRStatuses([]string{
+		"https://api.github.com/repos/owner/repo/pulls/1",
+		"https://api.github.com/repos/owner/repo/pulls/2",
+		"https://api.github.com/repos/gone/repo/pulls/3",
+	})
+	if err == nil {
+		t.Error("Expected the missing repository error to be returned")
+	}
+
+	if len(statuses) != 2 {
+		t.Fatalf("Expected 2 resolved statuses, got %d", len(statuses))
+	}
+	merged := statuses["https://api.github.com/repos/owner/repo/pulls/1"]
+	if merged.State != PRStateMerged || merged.Author != "octocat" || merged.MergedAt == nil {
+		t.Errorf("Expected merged PR by octocat, got %+v", merged)
+	}
+	if draft := statuses["https://api.github.com/repos/owner/repo/pulls/2"]; draft.State != PRStateDraft {
+		t.Errorf("Expected draft PR, got %+v", draft)
+	}
+}
+
+func TestGraphQLPRService_Batches(t *testing.T) {
+	var batches []int
+	client := &mockGraphQLClient{
+		doFunc: func(query string, variables map[string]interface{}, response interface{}) error {
+			batches = append(batches, len(variables)/3)
+			return nil
+		},
+	}
+
+	var urls []string
+	for i := range 250 {
+		urls = append(urls, fmt.Sprintf("https://api.github.com/repos/owner/repo/pulls/%d", i+1))
+	}
+
+	if _, err := NewGraphQLPRService(client, &mockPRService{}).GetPRStatuses(urls); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if fmt.Sprint(batches) != "[100 100 50]" {
+		t.Errorf("Expected batches of [100 100 50], got %v", batches)
+	}
+}
@@ -0,0 +1,75 @@
+package core
+
+import "time"
+
+const (
+	IssueStateOpen       = "open"
+	IssueStateClosed     = "closed"
+	IssueStateCompleted  = "completed"
+	IssueStateNotPlanned = "not_planned"
+	IssueStateDuplicate  = "duplicate"
+)
+
+type Issue struct {
+	State       string `json:"state"`
+	StateReason string `json:"state_reason"`
+	Title       string `json:"title"`
+	User        User   `json:"user"`
+}
+
+// IssueStatus is what dailyare knows about an issue, along with when it was
+// fetched so that cached copies can be refreshed.
+type IssueStatus struct {
+	Closed      bool      `json:"closed"`
+	StateReason string    `json:"state_reason,omitempty"`
+	Author      string    `json:"author,omitempty"`
+	FetchedAt   time.Time `json:"fetched_at"`
+	// UsedAt is when the cache last stored or returned the status.
+	UsedAt time.Time `json:"used_at,omitzero"`
+}
+
+// State returns open for open issues and the close reason for closed ones.
+// Issues closed before GitHub recorded reasons count as completed.
+func (s IssueStatus) State() string {
+	if !s.Closed {
+		return IssueStateOpen
+	}
+	switch s.StateReason {
+	case IssueStateNotPlanned, IssueStateDuplicate:
+		return s.StateReason
+	default:
+		return IssueStateCompleted
+	}
+}
+
+// IsTerminal reports whether the status is cached permanently. Closed issues
+// can be reopened, but that is rare enough not to be worth re-checking.
+func (s IssueStatus) IsTerminal() bool {
+	return s.Closed
+}
+
+type IssueService interface {
+	GetIssueStatus(url string) (IssueStatus, error)
+}
+
+type githubIssueService struct {
+	client GithubClient
+}
+
+func NewGithubIssueService(client GithubClient) IssueService {
+	return &githubIssueService{client: client}
+}
+
+func (s *githubIssueService) GetIssueStatus(url string) (IssueStatus, error) {
+	apiURL := formatGithubURL(url)
+	var issue Issue
+	err := s.client.Get(apiURL, &issue)
+	if err != nil {
+		return IssueStatus{}, err
+	}
+	return IssueStatus{
+		Closed:      issue.State == IssueStateClosed,
+		StateReason: issue.StateReason,
+		Author:      issue.User.Login,
+	}, nil
+}
@@ -0,0 +1,76 @@
+package core
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestGithubIssueService_GetIssueStatus(t *testing.T) {
+	tests := []struct {
+		name      string
+		mockResp  Issue
+		mockErr   error
+		wantState string
+		wantErr   bool
+	}{
+		{
+			name:      "Open issue",
+			mockResp:  Issue{State: "open"},
+			wantState: IssueStateOpen,
+		},
+		{
+			name:      "Completed issue",
+			mockResp:  Issue{State: "closed", StateReason: "completed"},
+			wantState: IssueStateCompleted,
+		},
+		{
+			name:      "Not planned issue",
+			mockResp:  Issue{State: "closed", StateReason: "not_planned"},
+			wantState: IssueStateNotPlanned,
+		},
+		{
+			name:      "Closed before state reasons",
+			mockResp:  Issue{State: "closed"},
+			wantState: IssueStateCompleted,
+		},
+		{
+			name:      "Reopened issue",
+			mockResp:  Issue{State: "open", StateReason: "reopened"},
+			wantState: IssueStateOpen,
+		},
+		{
+			name:    "API Error",
+			mockErr: errors.New("API error"),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &mockGithubClient{
+				getFunc: func(url string, response interface{}) error {
+					if url != "repos/owner/repo/issues/1" {
+						t.Errorf("Expected URL repos/owner/repo/issues/1, got %s", url)
+					}
+					if tt.mockErr != nil {
+						return tt.mockErr
+					}
+					issue := response.(*Issue)
+					*issue = tt.mockResp
+					return nil
+				},
+			}
+
+			service := NewGithubIssueService(client)
+			status, err := service.GetIssueStatus("https://api.github.com/repos/owner/repo/issues/1")
+
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GetIssueStatus() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err == nil && status.State() != tt.wantState {
+				t.Errorf("GetIssueStatus() state = %q, want %q", status.State(), tt.wantState)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const lockPollInterval = 250 * time.Millisecond
+
+// ErrLocked is returned when another dailyare run holds the run lock.
+var ErrLocked = errors.New("another dailyare run is in progress")
+
+// RunLock is an advisory lock that keeps overlapping runs from clobbering
+// each other's cache. The operating system releases it if dailyare dies.
+type RunLock struct {
+	file *os.File
+}
+
+// AcquireRunLock takes the run lock in dir, waiting up to wait for another
+// run to release it.
+func AcquireRunLock(dir string, wait time.Duration) (*RunLock, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(dir, "dailyare.lock")
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(wait)
+	for {
+		locked, err := tryLock(file)
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+		}
+		if locked {
+			break
+		}
+		if !time.Now().Before(deadline) {
+			holder := lockHolder(path)
+			file.Close()
+			if holder != "" {
+				return nil, fmt.Errorf("%w (pid %s holds %s)", ErrLocked, holder, path)
+			}
+			return nil, fmt.Errorf("%w (%s is held)", ErrLocked, path)
+		}
+		time.Sleep(min(lockPollInterval, time.Until(deadline)))
+	}
+
+	// The pid is only informational, for the error shown to a waiting run.
+	if err := file.Truncate(0); err == nil {
+		_, _ = file.WriteAt([]byte(strconv.Itoa(os.Getpid())), 0)
+	}
+	return &RunLock{file: file}, nil
+}
+
+// Release unlocks and closes the lock file.
+func (l *RunLock) Release() error {
+	if err := unlock(l.file); err != nil {
+		l.file.Close()
+		return err
+	}
+	return l.file.Close()
+}
+
+func lockHolder(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
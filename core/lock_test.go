@@ -0,0 +1,38 @@
+package core
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAcquireRunLock(t *testing.T) {
+	dir := t.TempDir()
+
+	lock, err := AcquireRunLock(dir, 0)
+	if err != nil {
+		t.Fatalf("Failed to acquire lock: %v", err)
+	}
+
+	if _, err := AcquireRunLock(dir, 0); !errors.Is(err, ErrLocked) {
+		t.Fatalf("Expected ErrLocked while the lock is held, got %v", err)
+	}
+
+	released := make(chan struct{})
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		if err := lock.Release(); err != nil {
+			t.Errorf("Failed to release lock: %v", err)
+		}
+		close(released)
+	}()
+
+	second, err := AcquireRunLock(dir, 5*time.Second)
+	if err != nil {
+		t.Fatalf("Expected to acquire the lock after waiting, got %v", err)
+	}
+	<-released
+	if err := second.Release(); err != nil {
+		t.Errorf("Failed to release lock: %v", err)
+	}
+}
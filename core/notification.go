@@ -1,115 +1,634 @@
 package core
 
 import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
 	"github.com/go-logr/logr"
 )
 
+const defaultStatusTTL = time.Hour
+
+const (
+	// SinceAuto fetches the notifications updated since the last complete
+	// sweep, falling back to defaultSince when there has not been one.
+	SinceAuto    = "auto"
+	defaultSince = "7d"
+	// watermarkOverlap is subtracted from the watermark so that
+	// notifications updated while the last sweep ran are not missed.
+	watermarkOverlap = 10 * time.Minute
+)
+
+const (
+	SubscriptionKeep        = "keep"
+	SubscriptionUnsubscribe = "unsubscribe"
+	SubscriptionIgnore      = "ignore"
+)
+
+// Notification is a notification thread as returned by the notifications API.
 type Notification struct {
-	ID      string  `json:"id"`
-	Subject Subject `json:"subject"`
+	ID              string     `json:"id"`
+	Reason          string     `json:"reason"`
+	Unread          bool       `json:"unread"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+	LastReadAt      *time.Time `json:"last_read_at"`
+	Subject         Subject    `json:"subject"`
+	Repository      Repository `json:"repository"`
+	URL             string     `json:"url"`
+	SubscriptionURL string     `json:"subscription_url"`
+}
+
+type Repository struct {
+	FullName string `json:"full_name"`
+	Owner    User   `json:"owner"`
+	Private  bool   `json:"private"`
+	Archived bool   `json:"archived"`
+	Fork     bool   `json:"fork"`
 }
 
 type Subject struct {
-	Title string `json:"title"`
-	Type  string `json:"type"`
-	URL   string `json:"url"`
+	Title            string `json:"title"`
+	Type             string `json:"type"`
+	URL              string `json:"url"`
+	LatestCommentURL string `json:"latest_comment_url"`
 }
 
 type NotificationService interface {
-	FetchNotifications(logger logr.Logger, since string, noCache bool) error
+	// FetchNotifications plans and applies a sweep and returns its summary,
+	// along with ErrIncomplete if the plan did not cover every notification.
+	FetchNotifications(logger logr.Logger, since string, noCache bool) (*Summary, error)
+	Plan(logger logr.Logger, since string, noCache bool) (*Plan, error)
+	// Apply clears the notifications in the plan and adds what happened to
+	// the plan's summary. It returns ErrClearFailed if any could not be
+	// cleared.
+	Apply(logger logr.Logger, plan *Plan) error
+	// PollInterval returns how long GitHub asked clients to wait before
+	// fetching notifications again, or zero if it has not said.
+	PollInterval() time.Duration
 }
 
 type NotificationRepository interface {
 	Delete(id string) error
+	MarkRead(id string) error
+	Unsubscribe(id string) error
+	Ignore(id string) error
 	GetByTimePeriod(since string) ([]Notification, error)
 }
 
+// PollingRepository is a NotificationRepository that remembers the poll
+// interval GitHub returned with the last fetch.
+type PollingRepository interface {
+	NotificationRepository
+	PollInterval() time.Duration
+}
+
+// NarrowedRepository is a NotificationRepository that may fetch only some of
+// the notifications in a window.
+type NarrowedRepository interface {
+	NotificationRepository
+	Narrowed() bool
+}
+
 type notificationService struct {
 	notificationRepo NotificationRepository
 	prService        PRService
+	issueService     IssueService
 	cacheService     CacheService
+	statusTTL        time.Duration
+	rules            *RuleSet
+	filter           Filter
+	subscription     string
+	concurrency      int
+	now              func() time.Time
+}
+
+type ServiceOption func(*notificationService)
+
+// WithStatusTTL sets how long a cached status for a PR or issue that is
+// still open is trusted before it is fetched again. Closed PRs and issues
+// are cached permanently.
+func WithStatusTTL(ttl time.Duration) ServiceOption {
+	return func(s *notificationService) {
+		s.statusTTL = ttl
+	}
 }
 
-func NewNotificationService(repo NotificationRepository, pr PRService, cache CacheService) NotificationService {
-	return &notificationService{
+// WithRules sets the rules deciding what happens to each notification.
+func WithRules(rules *RuleSet) ServiceOption {
+	return func(s *notificationService) {
+		s.rules = rules
+	}
+}
+
+// WithFilter limits sweeps to the notifications the filter includes.
+func WithFilter(filter Filter) ServiceOption {
+	return func(s *notificationService) {
+		s.filter = filter
+	}
+}
+
+// WithSubscription sets what happens to the thread subscription after a
+// thread is cleared: keep it, unsubscribe, or ignore the thread so that later
+// activity never notifies again.
+func WithSubscription(mode string) ServiceOption {
+	return func(s *notificationService) {
+		s.subscription = mode
+	}
+}
+
+// WithConcurrency sets how many notifications are evaluated or cleared at
+// the same time.
+func WithConcurrency(n int) ServiceOption {
+	return func(s *notificationService) {
+		if n > 0 {
+			s.concurrency = n
+		}
+	}
+}
+
+func NewNotificationService(repo NotificationRepository, pr PRService, issue IssueService, cache CacheService, opts ...ServiceOption) NotificationService {
+	s := &notificationService{
 		notificationRepo: repo,
 		prService:        pr,
+		issueService:     issue,
 		cacheService:     cache,
+		statusTTL:        defaultStatusTTL,
+		subscription:     SubscriptionKeep,
+		concurrency:      1,
+		now:              time.Now,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.rules == nil {
+		s.rules, _ = NewRuleSet(DefaultRules(DefaultRuleOptions{}))
 	}
+	return s
 }
 
-func (s *notificationService) FetchNotifications(logger logr.Logger, since string, noCache bool) error {
-	notifications, err := s.notificationRepo.GetByTimePeriod(since)
+func (s *notificationService) FetchNotifications(logger logr.Logger, since string, noCache bool) (*Summary, error) {
+	start := s.now()
+	plan, err := s.Plan(logger, since, noCache)
 	if err != nil {
-		return err
+		s.recordRun(logger, RunRecord{StartedAt: start, Since: since}, err)
+		return nil, err
 	}
+	err = s.Apply(logger, plan)
+	return &plan.Summary, errors.Join(plan.Incomplete(), err)
+}
 
-	logger.V(1).Info("Fetched notifications", "count", len(notifications))
+func (s *notificationService) PollInterval() time.Duration {
+	if repo, ok := s.notificationRepo.(PollingRepository); ok {
+		return repo.PollInterval()
+	}
+	return 0
+}
 
+// narrowed reports whether sweeps skip some of the notifications in their
+// window, because of the filter or because the repository fetches only some.
+func (s *notificationService) narrowed() bool {
+	if repo, ok := s.notificationRepo.(NarrowedRepository); ok && repo.Narrowed() {
+		return true
+	}
+	return !s.filter.IsEmpty()
+}
+
+// Plan decides which notifications would be cleared without clearing any of
+// them. Fetched PR statuses are cached, but no thread is marked as deleted.
+func (s *notificationService) Plan(logger logr.Logger, since string, noCache bool) (*Plan, error) {
+	// The cache is loaded first because it also holds the validators for
+	// conditional requests and the watermark.
 	cache, err := s.cacheService.Load()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
+	auto := since == SinceAuto
+	if auto {
+		since = s.autoSince(noCache)
+		logger.V(1).Info("Resolved automatic window", "since", since)
+	}
+
+	start := s.now()
+	complete := true
+	notifications, err := s.notificationRepo.GetByTimePeriod(since)
+	if errors.Is(err, ErrTruncated) {
+		logger.Info("Not every notification in the window was fetched", "error", err.Error())
+		complete = false
+	} else if err != nil {
+		return nil, err
+	}
+
+	logger.V(1).Info("Fetched notifications", "count", len(notifications))
+	if sinceTime, err := ParseTime(since, start); err == nil {
+		s.cacheService.SetMaxWindow(start.Sub(sinceTime))
+	}
+
+	plan := &Plan{
+		CreatedAt: s.now(),
+		Since:     since,
+		Truncated: !complete,
+		Decisions: []Decision{},
+	}
+
+	var included []Notification
+	for _, n := range notifications {
+		if s.filter.Includes(n) {
+			included = append(included, n)
+			continue
+		}
+		plan.Summary.record(n.Repository.FullName, Counts{Fetched: 1, Filtered: 1})
+	}
+	if len(included) != len(notifications) {
+		logger.V(1).Info("Filtered notifications", "kept", len(included), "skipped", len(notifications)-len(included))
+		notifications = included
+	}
+
+	prefetched := s.prefetchPRStatuses(logger, notifications, noCache)
+
+	decisions := make([]*Decision, len(notifications))
+	counts := make([]Counts, len(notifications))
+	s.forEach(len(notifications), func(i int) {
+		n := notifications[i]
+		logger := notificationLogger(logger, n)
+		counts[i].Fetched = 1
+
+		if !noCache && s.cacheService.IsThreadDeleted(n.ID, n.UpdatedAt) {
+			logger.V(1).Info("Skipping already deleted thread")
+			counts[i].Skipped = 1
+			return
+		}
+
+		counts[i].Checked = 1
+		decision, err := s.decide(logger, n, plan.CreatedAt, noCache, prefetched)
+		if err != nil {
+			logger.Error(err, "Failed to evaluate rules")
+			counts[i].Failed = 1
+			return
+		}
+		decisions[i] = decision
+	})
+	for i, decision := range decisions {
+		plan.Summary.record(notifications[i].Repository.FullName, counts[i])
+		if decision != nil {
+			plan.Decisions = append(plan.Decisions, *decision)
+		}
+	}
+
+	plan.Unevaluated = plan.Summary.Failed
+
+	if auto && plan.Incomplete() == nil {
+		if s.narrowed() {
+			// The next sweep would start after notifications this one
+			// never saw, so only a sweep of everything moves the watermark.
+			logger.V(1).Info("Not advancing the watermark of a filtered sweep")
+		} else {
+			plan.Watermark = start
+		}
+	}
+
+	return plan, s.cacheService.Save(cache)
+}
+
+// notificationLogger identifies the notification on every line logged about
+// it.
+func notificationLogger(logger logr.Logger, n Notification) logr.Logger {
+	return logger.WithValues(
+		"id", n.ID,
+		"title", n.Subject.Title,
+		"repository", n.Repository.FullName,
+		"reason", n.Reason)
+}
+
+// autoSince returns the window for --since auto: the last complete sweep
+// minus an overlap, or the default window before the first one.
+func (s *notificationService) autoSince(noCache bool) string {
+	last := s.cacheService.GetLastSweep()
+	if noCache || last.IsZero() {
+		return defaultSince
+	}
+	return last.Add(-watermarkOverlap).UTC().Format(time.RFC3339)
+}
+
+// prefetchPRStatuses resolves in batches the pull requests the rules will
+// need, when the PR service supports it. Anything it cannot resolve is looked
+// up one at a time during evaluation.
+func (s *notificationService) prefetchPRStatuses(logger logr.Logger, notifications []Notification, noCache bool) map[string]PRStatus {
+	batch, ok := s.prService.(BatchPRService)
+	if !ok || !s.rules.NeedsPRStatus() {
+		return nil
+	}
+
+	var urls []string
+	seen := make(map[string]bool)
 	for _, notification := range notifications {
-		if notification.Subject.Type == "PullRequest" {
-			if !noCache && s.cacheService.IsThreadDeleted(notification.ID) {
-				logger.V(1).Info("Skipping already deleted thread",
-					"title", notification.Subject.Title,
-					"id", notification.ID)
+		url := notification.Subject.URL
+		if notification.Subject.Type != "PullRequest" || seen[url] {
+			continue
+		}
+		seen[url] = true
+		if !noCache {
+			if s.cacheService.IsThreadDeleted(notification.ID, notification.UpdatedAt) {
 				continue
 			}
-
-			logger.V(1).Info("Checking PR status",
-				"title", notification.Subject.Title,
-				"id", notification.ID)
-
-			merged, err := s.handlePullRequest(notification.Subject.URL, noCache)
-			if err != nil {
-				logger.Error(err, "Failed to handle pull request")
+			if status, exists := s.cacheService.GetPRStatus(url); exists && s.isFresh(status.FetchedAt, status.IsTerminal()) {
 				continue
 			}
+		}
+		urls = append(urls, url)
+	}
+	if len(urls) == 0 {
+		return nil
+	}
 
-			if !merged {
-				logger.V(1).Info("PR not merged, skipping deletion",
-					"title", notification.Subject.Title,
-					"id", notification.ID)
-				continue
+	logger.V(1).Info("Prefetching PR statuses", "count", len(urls))
+	statuses, err := batch.GetPRStatuses(urls)
+	if err != nil {
+		logger.V(1).Info("Batch PR lookup incomplete, falling back to single lookups",
+			"error", err.Error(),
+			"resolved", len(statuses),
+			"requested", len(urls))
+	}
+
+	now := s.now()
+	for url, status := range statuses {
+		status.FetchedAt = now
+		statuses[url] = status
+		if !noCache {
+			s.cacheService.SetPRStatus(url, status)
+		}
+	}
+	return statuses
+}
+
+// decide evaluates the rules for one notification that has not been cleared
+// yet and returns the decision, or nil if the notification is kept.
+func (s *notificationService) decide(logger logr.Logger, notification Notification, now time.Time, noCache bool, prefetched map[string]PRStatus) (*Decision, error) {
+	rule, err := s.rules.Evaluate(notification, now, SubjectLookup{
+		PRStatus: func() (PRStatus, error) {
+			if status, ok := prefetched[notification.Subject.URL]; ok {
+				return status, nil
 			}
+			logger.V(1).Info("Checking PR status")
+			return s.handlePullRequest(notification.Subject.URL, noCache)
+		},
+		IssueStatus: func() (IssueStatus, error) {
+			logger.V(1).Info("Checking issue status")
+			return s.handleIssue(notification.Subject.URL, noCache)
+		},
+		Reactivated: func() bool {
+			return !noCache && s.cacheService.IsThreadReactivated(notification.ID, notification.UpdatedAt)
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
 
-			logger.V(1).Info("Deleting notification for merged PR",
-				"title", notification.Subject.Title,
-				"id", notification.ID)
+	if rule.Action == ActionKeep {
+		logger.V(1).Info("Keeping notification", "rule", rule.Name)
+		return nil, nil
+	}
 
-			err = s.notificationRepo.Delete(notification.ID)
-			if err != nil {
-				logger.Error(err, "Failed to delete notification")
-				continue
+	return &Decision{
+		ThreadID:           notification.ID,
+		Title:              notification.Subject.Title,
+		Type:               notification.Subject.Type,
+		URL:                notification.Subject.URL,
+		Repository:         notification.Repository.FullName,
+		NotificationReason: notification.Reason,
+		UpdatedAt:          notification.UpdatedAt,
+		Action:             rule.Action,
+		Reason:             rule.Name,
+	}, nil
+}
+
+// Apply clears every notification in the plan and records it in the cache.
+// The plan's watermark is only recorded when every notification was cleared.
+func (s *notificationService) Apply(logger logr.Logger, plan *Plan) error {
+	cache, err := s.cacheService.Load()
+	if err != nil {
+		return err
+	}
+
+	cleared := make([]bool, len(plan.Decisions))
+	s.forEach(len(plan.Decisions), func(i int) {
+		cleared[i] = s.clear(logger, plan.Decisions[i])
+	})
+
+	failed := 0
+	for i, decision := range plan.Decisions {
+		if cleared[i] {
+			plan.Summary.record(decision.Repository, Counts{Cleared: 1})
+		} else {
+			plan.Summary.record(decision.Repository, Counts{Failed: 1})
+			failed++
+		}
+	}
+
+	if !plan.Watermark.IsZero() {
+		if failed == 0 {
+			s.cacheService.SetLastSweep(plan.Watermark)
+		} else {
+			logger.Info("Not advancing the watermark after failures", "failed", failed)
+		}
+	}
+
+	err = s.cacheService.Save(cache)
+	if err == nil && failed > 0 {
+		err = fmt.Errorf("%w: %d of %d failed", ErrClearFailed, failed, len(plan.Decisions))
+	}
+	run := RunRecord{StartedAt: plan.CreatedAt, Since: plan.Since, Summary: plan.Summary}
+	s.recordRun(logger, run, errors.Join(plan.Incomplete(), err))
+	return err
+}
+
+// recordRun adds a finished run to the history, if the cache keeps one.
+func (s *notificationService) recordRun(logger logr.Logger, run RunRecord, err error) {
+	history, ok := s.cacheService.(RunHistory)
+	if !ok {
+		return
+	}
+	run.FinishedAt = s.now()
+	if err != nil {
+		run.Error = err.Error()
+	}
+	if err := history.RecordRun(run); err != nil {
+		logger.Error(err, "Failed to record run history")
+	}
+}
+
+// clear executes a decision and reports whether the thread was cleared.
+func (s *notificationService) clear(logger logr.Logger, decision Decision) bool {
+	logger = logger.WithValues(
+		"id", decision.ThreadID,
+		"title", decision.Title,
+		"repository", decision.Repository,
+		"reason", decision.NotificationReason)
+	logger.V(1).Info("Clearing notification",
+		"action", decision.Action,
+		"rule", decision.Reason)
+
+	err := s.execute(decision)
+	if err != nil {
+		logger.Error(err, "Failed to clear notification")
+		return false
+	}
+
+	// The thread is only recorded as cleared once its subscription is
+	// updated too, so that a failure is counted and retried on the next run.
+	if s.subscription != SubscriptionKeep && decision.Action != ActionUnsubscribe {
+		err = s.updateSubscription(decision.ThreadID, s.subscription)
+		if err != nil {
+			logger.Error(err, "Failed to update thread subscription", "subscription", s.subscription)
+			return false
+		}
+	}
+
+	s.cacheService.SetThreadDeleted(decision.ThreadID, decision.UpdatedAt)
+	logger.V(1).Info("Successfully cleared notification")
+	return true
+}
+
+// forEach calls fn for every index below n, using up to s.concurrency
+// goroutines. fn must be safe to call concurrently when concurrency is
+// above one.
+func (s *notificationService) forEach(n int, fn func(i int)) {
+	workers := min(s.concurrency, n)
+	if workers <= 1 {
+		for i := range n {
+			fn(i)
+		}
+		return
+	}
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Go(func() {
+			for i := range indexes {
+				fn(i)
 			}
-			s.cacheService.SetThreadDeleted(notification.ID)
-			logger.V(1).Info("Successfully deleted notification",
-				"title", notification.Subject.Title,
-				"id", notification.ID)
+		})
+	}
+	for i := range n {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+}
+
+// execute performs a decision's action. Unsubscribing also marks the thread
+// as done so it leaves the inbox.
+func (s *notificationService) execute(decision Decision) error {
+	switch decision.Action {
+	case ActionDone:
+		return s.notificationRepo.Delete(decision.ThreadID)
+	case ActionRead:
+		return s.notificationRepo.MarkRead(decision.ThreadID)
+	case ActionUnsubscribe:
+		if err := s.updateSubscription(decision.ThreadID, SubscriptionUnsubscribe); err != nil {
+			return err
+		}
+		return s.notificationRepo.Delete(decision.ThreadID)
+	default:
+		return fmt.Errorf("unknown action: %q", decision.Action)
+	}
+}
+
+// updateSubscription unsubscribes from or ignores a thread once, recording
+// it in the cache so later runs do not repeat the call.
+func (s *notificationService) updateSubscription(id string, mode string) error {
+	if s.cacheService.IsThreadUnsubscribed(id) {
+		return nil
+	}
+
+	var err error
+	switch mode {
+	case SubscriptionUnsubscribe:
+		err = s.notificationRepo.Unsubscribe(id)
+	case SubscriptionIgnore:
+		err = s.notificationRepo.Ignore(id)
+	default:
+		return fmt.Errorf("unknown subscription mode: %q", mode)
+	}
+	if err != nil {
+		return err
+	}
+
+	s.cacheService.SetThreadUnsubscribed(id)
+	return nil
+}
+
+func (s *notificationService) handlePullRequest(url string, noCache bool) (PRStatus, error) {
+	var cached PRStatus
+	var exists bool
+	if !noCache {
+		cached, exists = s.cacheService.GetPRStatus(url)
+		if exists && s.isFresh(cached.FetchedAt, cached.IsTerminal()) {
+			return cached, nil
 		}
 	}
 
-	return s.cacheService.Save(cache)
+	status, err := revalidate(s.cacheService, func() (PRStatus, error) { return s.prService.GetPRStatus(url) }, cached, exists)
+	if err != nil {
+		return PRStatus{}, err
+	}
+	status.FetchedAt = s.now()
+
+	if !noCache {
+		s.cacheService.SetPRStatus(url, status)
+	}
+	return status, nil
 }
 
-func (s *notificationService) handlePullRequest(url string, noCache bool) (bool, error) {
+func (s *notificationService) handleIssue(url string, noCache bool) (IssueStatus, error) {
+	var cached IssueStatus
+	var exists bool
 	if !noCache {
-		if merged, exists := s.cacheService.GetPRStatus(url); exists {
-			return merged, nil
+		cached, exists = s.cacheService.GetIssueStatus(url)
+		if exists && s.isFresh(cached.FetchedAt, cached.IsTerminal()) {
+			return cached, nil
 		}
 	}
 
-	merged, err := s.prService.GetPRStatus(url)
+	status, err := revalidate(s.cacheService, func() (IssueStatus, error) { return s.issueService.GetIssueStatus(url) }, cached, exists)
 	if err != nil {
-		return false, err
+		return IssueStatus{}, err
 	}
+	status.FetchedAt = s.now()
 
 	if !noCache {
-		s.cacheService.SetPRStatus(url, merged)
+		s.cacheService.SetIssueStatus(url, status)
+	}
+	return status, nil
+}
+
+// revalidate fetches a status, answering a 304 Not Modified with the cached
+// one. Without a cached status the validators are dropped and the status is
+// fetched again, unconditionally.
+func revalidate[T any](cache CacheService, fetch func() (T, error), cached T, exists bool) (T, error) {
+	status, err := fetch()
+	var notModified *NotModifiedError
+	if !errors.As(err, &notModified) {
+		return status, err
+	}
+	if exists {
+		return cached, nil
+	}
+	if _, err := cache.Forget(CacheKindResponse, notModified.Key); err != nil {
+		return status, err
+	}
+	return fetch()
+}
+
+func (s *notificationService) isFresh(fetchedAt time.Time, terminal bool) bool {
+	if fetchedAt.IsZero() {
+		return false
+	}
+	if terminal {
+		return true
 	}
-	return merged, nil
+	return s.now().Sub(fetchedAt) < s.statusTTL
 }
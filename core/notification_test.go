@@ -1,54 +1,205 @@
 package core
 
 import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/go-logr/logr/testr"
 )
 
 type mockNotificationRepo struct {
 	deleteFunc          func(id string) error
+	markReadFunc        func(id string) error
+	unsubscribeFunc     func(id string) error
+	ignoreFunc          func(id string) error
 	getByTimePeriodFunc func(since string) ([]Notification, error)
+	narrowed            bool
 }
 
 func (m *mockNotificationRepo) Delete(id string) error {
 	return m.deleteFunc(id)
 }
 
+func (m *mockNotificationRepo) MarkRead(id string) error {
+	return m.markReadFunc(id)
+}
+
+func (m *mockNotificationRepo) Unsubscribe(id string) error {
+	return m.unsubscribeFunc(id)
+}
+
+func (m *mockNotificationRepo) Ignore(id string) error {
+	return m.ignoreFunc(id)
+}
+
 func (m *mockNotificationRepo) GetByTimePeriod(since string) ([]Notification, error) {
 	return m.getByTimePeriodFunc(since)
 }
 
+func (m *mockNotificationRepo) Narrowed() bool {
+	return m.narrowed
+}
+
 type mockPRService struct {
-	getPRStatusFunc func(url string) (bool, error)
+	getPRStatusFunc func(url string) (PRStatus, error)
 }
 
-func (m *mockPRService) GetPRStatus(url string) (bool, error) {
+func (m *mockPRService) GetPRStatus(url string) (PRStatus, error) {
 	return m.getPRStatusFunc(url)
 }
 
+type mockIssueService struct {
+	getIssueStatusFunc func(url string) (IssueStatus, error)
+}
+
+func (m *mockIssueService) GetIssueStatus(url string) (IssueStatus, error) {
+	return m.getIssueStatusFunc(url)
+}
+
 type mockCacheService struct {
+	mu    sync.Mutex
 	cache *Cache
 }
 
 func newMockCacheService() *mockCacheService {
 	return &mockCacheService{
 		cache: &Cache{
-			PRStatus:       make(map[string]bool),
-			ThreadsDeleted: make(map[string]bool),
+			PRStatus:            make(map[string]PRStatus),
+			IssueStatus:         make(map[string]IssueStatus),
+			ThreadsDeleted:      make(map[string]ClearedThread),
+			ThreadsUnsubscribed: make(map[string]bool),
+			Responses:           make(map[string]CachedResponse),
 		},
 	}
 }
 
-func (m *mockCacheService) Load() (*Cache, error)          { return m.cache, nil }
-func (m *mockCacheService) Save(*Cache) error              { return nil }
-func (m *mockCacheService) IsThreadDeleted(id string) bool { return m.cache.ThreadsDeleted[id] }
-func (m *mockCacheService) SetThreadDeleted(id string)     { m.cache.ThreadsDeleted[id] = true }
-func (m *mockCacheService) GetPRStatus(url string) (bool, bool) {
+func (m *mockCacheService) Load() (*Cache, error) { return m.cache, nil }
+func (m *mockCacheService) Save(*Cache) error     { return nil }
+
+func (m *mockCacheService) IsThreadDeleted(id string, updatedAt time.Time) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cleared, ok := m.cache.ThreadsDeleted[id]
+	return ok && !updatedAt.After(cleared.UpdatedAt)
+}
+
+func (m *mockCacheService) IsThreadReactivated(id string, updatedAt time.Time) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cleared, ok := m.cache.ThreadsDeleted[id]
+	return ok && updatedAt.After(cleared.UpdatedAt)
+}
+
+func (m *mockCacheService) SetThreadDeleted(id string, updatedAt time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.cache.ThreadsDeleted[id] = ClearedThread{UpdatedAt: updatedAt}
+}
+
+func (m *mockCacheService) IsThreadUnsubscribed(id string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.cache.ThreadsUnsubscribed[id]
+}
+
+func (m *mockCacheService) SetThreadUnsubscribed(id string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.cache.ThreadsUnsubscribed[id] = true
+}
+
+func (m *mockCacheService) GetPRStatus(url string) (PRStatus, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	v, ok := m.cache.PRStatus[url]
 	return v, ok
 }
-func (m *mockCacheService) SetPRStatus(url string, merged bool) { m.cache.PRStatus[url] = merged }
+
+func (m *mockCacheService) SetPRStatus(url string, status PRStatus) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.cache.PRStatus[url] = status
+}
+
+func (m *mockCacheService) GetIssueStatus(url string) (IssueStatus, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, ok := m.cache.IssueStatus[url]
+	return v, ok
+}
+
+func (m *mockCacheService) SetIssueStatus(url string, status IssueStatus) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.cache.IssueStatus[url] = status
+}
+
+func (m *mockCacheService) GetLastSweep() time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.cache.LastSweep
+}
+
+func (m *mockCacheService) SetLastSweep(t time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if t.After(m.cache.LastSweep) {
+		m.cache.LastSweep = t
+	}
+}
+
+func (m *mockCacheService) GetMaxWindow() time.Duration {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.cache.MaxWindow
+}
+
+func (m *mockCacheService) SetMaxWindow(window time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.cache.MaxWindow = max(m.cache.MaxWindow, window)
+}
+
+// The other cache command methods are not used by the notification service.
+func (m *mockCacheService) Stats() CacheStats                    { return CacheStats{} }
+func (m *mockCacheService) Entries(string) ([]CacheEntry, error) { return nil, nil }
+func (m *mockCacheService) Prune() (map[string]int, error)       { return nil, nil }
+func (m *mockCacheService) Clear() error                         { return nil }
+func (m *mockCacheService) Close() error                         { return nil }
+func (m *mockCacheService) Export(io.Writer) error               { return nil }
+func (m *mockCacheService) Import(io.Reader) error               { return nil }
+
+// Forget only drops responses, which is all the notification service
+// forgets.
+func (m *mockCacheService) Forget(kind, key string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.cache.Responses[key]; kind != CacheKindResponse || !ok {
+		return 0, nil
+	}
+	delete(m.cache.Responses, key)
+	return 1, nil
+}
+
+func (m *mockCacheService) GetResponse(key string) (CachedResponse, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, ok := m.cache.Responses[key]
+	return v, ok
+}
+
+func (m *mockCacheService) SetResponse(key string, response CachedResponse) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.cache.Responses[key] = response
+}
 
 func TestNotificationService_FetchNotifications(t *testing.T) {
 	notificationRepo := &mockNotificationRepo{
@@ -64,24 +215,27 @@ func TestNotificationService_FetchNotifications(t *testing.T) {
 	}
 
 	prService := &mockPRService{
-		getPRStatusFunc: func(url string) (bool, error) {
-			return url == "pr1", nil
+		getPRStatusFunc: func(url string) (PRStatus, error) {
+			if url == "pr1" {
+				return PRStatus{State: PRStateMerged}, nil
+			}
+			return PRStatus{State: PRStateOpen}, nil
 		},
 	}
 
 	cacheService := newMockCacheService()
-	service := NewNotificationService(notificationRepo, prService, cacheService)
+	service := NewNotificationService(notificationRepo, prService, &mockIssueService{}, cacheService)
 	logger := testr.New(t)
 
-	err := service.FetchNotifications(logger, "7d", false)
+	_, err := service.FetchNotifications(logger, "7d", false)
 	if err != nil {
 		t.Fatalf("Unexpected error: %v", err)
 	}
 
-	if !cacheService.IsThreadDeleted("1") {
+	if !cacheService.IsThreadDeleted("1", time.Time{}) {
 		t.Error("Expected thread 1 to be marked as deleted")
 	}
-	if cacheService.IsThreadDeleted("2") {
+	if cacheService.IsThreadDeleted("2", time.Time{}) {
 		t.Error("Expected thread 2 to not be marked as deleted")
 	}
 }
@@ -99,19 +253,872 @@ func TestNotificationService_FetchNotifications_NoCache(t *testing.T) {
 	}
 
 	prService := &mockPRService{
-		getPRStatusFunc: func(url string) (bool, error) {
-			return true, nil
+		getPRStatusFunc: func(url string) (PRStatus, error) {
+			return PRStatus{State: PRStateMerged}, nil
 		},
 	}
 
 	cacheService := newMockCacheService()
-	cacheService.SetThreadDeleted("1") // Pre-mark as deleted
+	cacheService.SetThreadDeleted("1", time.Time{}) // Pre-mark as deleted
 
-	service := NewNotificationService(notificationRepo, prService, cacheService)
+	service := NewNotificationService(notificationRepo, prService, &mockIssueService{}, cacheService)
 	logger := testr.New(t)
 
-	err := service.FetchNotifications(logger, "7d", true)
+	_, err := service.FetchNotifications(logger, "7d", true)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
+
+func TestNotificationService_RefreshesStaleOpenPRStatus(t *testing.T) {
+	now := time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC)
+
+	notificationRepo := &mockNotificationRepo{
+		getByTimePeriodFunc: func(since string) ([]Notification, error) {
+			return []Notification{
+				{ID: "1", Subject: Subject{Type: "PullRequest", URL: "stale-open"}},
+				{ID: "2", Subject: Subject{Type: "PullRequest", URL: "fresh-open"}},
+				{ID: "3", Subject: Subject{Type: "PullRequest", URL: "old-merged"}},
+			}, nil
+		},
+		deleteFunc: func(id string) error {
+			return nil
+		},
+	}
+
+	var fetched []string
+	prService := &mockPRService{
+		getPRStatusFunc: func(url string) (PRStatus, error) {
+			fetched = append(fetched, url)
+			return PRStatus{State: PRStateMerged}, nil
+		},
+	}
+
+	cacheService := newMockCacheService()
+	cacheService.SetPRStatus("stale-open", PRStatus{State: PRStateOpen, FetchedAt: now.Add(-2 * time.Hour)})
+	cacheService.SetPRStatus("fresh-open", PRStatus{State: PRStateOpen, FetchedAt: now.Add(-10 * time.Minute)})
+	cacheService.SetPRStatus("old-merged", PRStatus{State: PRStateMerged, FetchedAt: now.Add(-30 * 24 * time.Hour)})
+
+	service := NewNotificationService(notificationRepo, prService, &mockIssueService{}, cacheService, WithStatusTTL(time.Hour))
+	service.(*notificationService).now = func() time.Time { return now }
+
+	_, err := service.FetchNotifications(testr.New(t), "7d", false)
 	if err != nil {
 		t.Fatalf("Unexpected error: %v", err)
 	}
+
+	if len(fetched) != 1 || fetched[0] != "stale-open" {
+		t.Errorf("Expected only stale-open to be fetched, got %v", fetched)
+	}
+	if status, _ := cacheService.GetPRStatus("stale-open"); status.State != PRStateMerged || !status.FetchedAt.Equal(now) {
+		t.Errorf("Expected stale-open to be refreshed as merged at %v, got %+v", now, status)
+	}
+	if !cacheService.IsThreadDeleted("1", time.Time{}) {
+		t.Error("Expected thread 1 to be deleted after its PR merged")
+	}
+	if cacheService.IsThreadDeleted("2", time.Time{}) {
+		t.Error("Expected thread 2 to be skipped while its cached status is fresh")
+	}
+}
+
+func TestNotificationService_NotModifiedUsesCachedStatus(t *testing.T) {
+	now := time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC)
+
+	notificationRepo := &mockNotificationRepo{
+		getByTimePeriodFunc: func(since string) ([]Notification, error) {
+			return []Notification{
+				{ID: "1", Subject: Subject{Type: "PullRequest", URL: "stale-open"}},
+				{ID: "2", Subject: Subject{Type: "PullRequest", URL: "uncached"}},
+			}, nil
+		},
+		deleteFunc: func(id string) error {
+			return nil
+		},
+	}
+
+	cacheService := newMockCacheService()
+	cacheService.SetPRStatus("stale-open", PRStatus{State: PRStateOpen, FetchedAt: now.Add(-2 * time.Hour)})
+	cacheService.SetResponse("uncached-response", CachedResponse{ETag: `"abc"`})
+
+	var fetched []string
+	prService := &mockPRService{
+		getPRStatusFunc: func(url string) (PRStatus, error) {
+			fetched = append(fetched, url)
+			if url == "stale-open" {
+				return PRStatus{}, &NotModifiedError{Key: "stale-open-response"}
+			}
+			// The validators are only sent while they are cached.
+			if _, ok := cacheService.GetResponse("uncached-response"); ok {
+				return PRStatus{}, &NotModifiedError{Key: "uncached-response"}
+			}
+			return PRStatus{State: PRStateMerged}, nil
+		},
+	}
+
+	service := NewNotificationService(notificationRepo, prService, &mockIssueService{}, cacheService, WithStatusTTL(time.Hour))
+	service.(*notificationService).now = func() time.Time { return now }
+
+	if _, err := service.FetchNotifications(testr.New(t), "7d", false); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if status, _ := cacheService.GetPRStatus("stale-open"); status.State != PRStateOpen || !status.FetchedAt.Equal(now) {
+		t.Errorf("Expected the cached status to be confirmed at %v, got %+v", now, status)
+	}
+	if want := []string{"stale-open", "uncached", "uncached"}; !slices.Equal(fetched, want) {
+		t.Errorf("Expected fetches %v, got %v", want, fetched)
+	}
+	if !cacheService.IsThreadDeleted("2", time.Time{}) {
+		t.Error("Expected thread 2 to be cleared after fetching its status again")
+	}
+}
+
+func TestNotificationService_PlanDoesNotClear(t *testing.T) {
+	notificationRepo := &mockNotificationRepo{
+		getByTimePeriodFunc: func(since string) ([]Notification, error) {
+			return []Notification{
+				{ID: "1", Subject: Subject{Type: "PullRequest", URL: "pr1", Title: "Merged"}},
+				{ID: "2", Subject: Subject{Type: "PullRequest", URL: "pr2", Title: "Open"}},
+			}, nil
+		},
+		deleteFunc: func(id string) error {
+			t.Errorf("Unexpected delete of thread %s during plan", id)
+			return nil
+		},
+	}
+
+	prService := &mockPRService{
+		getPRStatusFunc: func(url string) (PRStatus, error) {
+			if url == "pr1" {
+				return PRStatus{State: PRStateMerged}, nil
+			}
+			return PRStatus{State: PRStateOpen}, nil
+		},
+	}
+
+	cacheService := newMockCacheService()
+	service := NewNotificationService(notificationRepo, prService, &mockIssueService{}, cacheService)
+
+	plan, err := service.Plan(testr.New(t), "7d", false)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(plan.Decisions) != 1 {
+		t.Fatalf("Expected 1 decision, got %d", len(plan.Decisions))
+	}
+	if d := plan.Decisions[0]; d.ThreadID != "1" || d.Action != ActionDone {
+		t.Errorf("Expected thread 1 to be marked done, got %+v", d)
+	}
+	if cacheService.IsThreadDeleted("1", time.Time{}) {
+		t.Error("Expected plan to leave the deleted threads cache untouched")
+	}
+}
+
+func TestNotificationService_Apply(t *testing.T) {
+	var deleted []string
+	notificationRepo := &mockNotificationRepo{
+		deleteFunc: func(id string) error {
+			deleted = append(deleted, id)
+			if id == "2" {
+				return errors.New("delete failed")
+			}
+			return nil
+		},
+	}
+
+	cacheService := newMockCacheService()
+	service := NewNotificationService(notificationRepo, &mockPRService{}, &mockIssueService{}, cacheService)
+
+	plan := &Plan{Decisions: []Decision{
+		{ThreadID: "1", Action: ActionDone, Repository: "org/a"},
+		{ThreadID: "2", Action: ActionDone, Repository: "org/b"},
+	}}
+	if err := service.Apply(testr.New(t), plan); !errors.Is(err, ErrClearFailed) {
+		t.Fatalf("Expected ErrClearFailed, got %v", err)
+	}
+
+	if len(deleted) != 2 {
+		t.Errorf("Expected 2 deletes, got %v", deleted)
+	}
+	if !cacheService.IsThreadDeleted("1", time.Time{}) {
+		t.Error("Expected thread 1 to be marked as deleted")
+	}
+	if cacheService.IsThreadDeleted("2", time.Time{}) {
+		t.Error("Expected failed thread 2 to not be marked as deleted")
+	}
+	if plan.Summary.Cleared != 1 || plan.Summary.Failed != 1 {
+		t.Errorf("Expected 1 cleared and 1 failed, got %+v", plan.Summary.Counts)
+	}
+	if plan.Summary.Repositories["org/b"].Failed != 1 {
+		t.Errorf("Expected the failure to be counted for org/b, got %+v", plan.Summary.Repositories)
+	}
+}
+
+func TestNotificationService_ApplyActions(t *testing.T) {
+	var calls []string
+	notificationRepo := &mockNotificationRepo{
+		deleteFunc: func(id string) error {
+			calls = append(calls, "done:"+id)
+			return nil
+		},
+		markReadFunc: func(id string) error {
+			calls = append(calls, "read:"+id)
+			return nil
+		},
+		unsubscribeFunc: func(id string) error {
+			calls = append(calls, "unsubscribe:"+id)
+			return nil
+		},
+	}
+
+	cacheService := newMockCacheService()
+	service := NewNotificationService(notificationRepo, &mockPRService{}, &mockIssueService{}, cacheService)
+
+	plan := &Plan{Decisions: []Decision{
+		{ThreadID: "1", Action: ActionDone},
+		{ThreadID: "2", Action: ActionRead},
+		{ThreadID: "3", Action: ActionUnsubscribe},
+	}}
+	if err := service.Apply(testr.New(t), plan); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	want := []string{"done:1", "read:2", "unsubscribe:3", "done:3"}
+	if len(calls) != len(want) {
+		t.Fatalf("Expected calls %v, got %v", want, calls)
+	}
+	for i := range want {
+		if calls[i] != want[i] {
+			t.Errorf("Expected calls %v, got %v", want, calls)
+			break
+		}
+	}
+}
+
+func TestNotificationService_PlanWithRules(t *testing.T) {
+	notificationRepo := &mockNotificationRepo{
+		getByTimePeriodFunc: func(since string) ([]Notification, error) {
+			return []Notification{
+				{ID: "1", Reason: "ci_activity", Subject: Subject{Type: "CheckSuite"}},
+				{ID: "2", Reason: "mention", Subject: Subject{Type: "Issue"}},
+			}, nil
+		},
+	}
+
+	prService := &mockPRService{
+		getPRStatusFunc: func(url string) (PRStatus, error) {
+			t.Errorf("Unexpected PR lookup for %s", url)
+			return PRStatus{}, nil
+		},
+	}
+
+	rules, err := NewRuleSet([]Rule{
+		{Name: "ci", Match: RuleMatch{Reason: []string{"ci_activity"}}, Action: ActionRead},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	service := NewNotificationService(notificationRepo, prService, &mockIssueService{}, newMockCacheService(), WithRules(rules))
+	plan, err := service.Plan(testr.New(t), "7d", false)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(plan.Decisions) != 1 {
+		t.Fatalf("Expected 1 decision, got %d", len(plan.Decisions))
+	}
+	if d := plan.Decisions[0]; d.ThreadID != "1" || d.Action != ActionRead || d.Reason != "ci" {
+		t.Errorf("Expected thread 1 to be read by rule ci, got %+v", d)
+	}
+}
+
+func TestNotificationService_ClosedIssues(t *testing.T) {
+	notificationRepo := &mockNotificationRepo{
+		getByTimePeriodFunc: func(since string) ([]Notification, error) {
+			return []Notification{
+				{ID: "1", Subject: Subject{Type: "Issue", URL: "completed"}},
+				{ID: "2", Subject: Subject{Type: "Issue", URL: "not-planned"}},
+				{ID: "3", Subject: Subject{Type: "Issue", URL: "open"}},
+			}, nil
+		},
+	}
+
+	issueService := &mockIssueService{
+		getIssueStatusFunc: func(url string) (IssueStatus, error) {
+			switch url {
+			case "completed":
+				return IssueStatus{Closed: true, StateReason: "completed"}, nil
+			case "not-planned":
+				return IssueStatus{Closed: true, StateReason: "not_planned"}, nil
+			default:
+				return IssueStatus{}, nil
+			}
+		},
+	}
+
+	tests := []struct {
+		name       string
+		notPlanned bool
+		want       []string
+	}{
+		{"completed only", false, []string{"1"}},
+		{"with not planned", true, []string{"1", "2"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules, err := NewRuleSet(DefaultRules(DefaultRuleOptions{ClearNotPlannedIssues: tt.notPlanned}))
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			cacheService := newMockCacheService()
+			service := NewNotificationService(notificationRepo, &mockPRService{}, issueService, cacheService, WithRules(rules))
+			plan, err := service.Plan(testr.New(t), "7d", false)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			var got []string
+			for _, d := range plan.Decisions {
+				got = append(got, d.ThreadID)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Expected threads %v to be cleared, got %v", tt.want, got)
+			}
+			if status, ok := cacheService.GetIssueStatus("completed"); !ok || !status.Closed {
+				t.Errorf("Expected completed issue status to be cached, got %+v", status)
+			}
+		})
+	}
+}
+
+func TestNotificationService_ApplySubscription(t *testing.T) {
+	tests := []struct {
+		mode string
+		want []string
+	}{
+		{SubscriptionKeep, []string{"done:1", "done:2"}},
+		{SubscriptionUnsubscribe, []string{"done:1", "unsubscribe:1", "done:2"}},
+		{SubscriptionIgnore, []string{"done:1", "ignore:1", "done:2"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			var calls []string
+			notificationRepo := &mockNotificationRepo{
+				deleteFunc: func(id string) error {
+					calls = append(calls, "done:"+id)
+					return nil
+				},
+				unsubscribeFunc: func(id string) error {
+					calls = append(calls, "unsubscribe:"+id)
+					return nil
+				},
+				ignoreFunc: func(id string) error {
+					calls = append(calls, "ignore:"+id)
+					return nil
+				},
+			}
+
+			cacheService := newMockCacheService()
+			cacheService.SetThreadUnsubscribed("2")
+
+			service := NewNotificationService(notificationRepo, &mockPRService{}, &mockIssueService{}, cacheService, WithSubscription(tt.mode))
+			plan := &Plan{Decisions: []Decision{
+				{ThreadID: "1", Action: ActionDone},
+				{ThreadID: "2", Action: ActionDone},
+			}}
+			if err := service.Apply(testr.New(t), plan); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if strings.Join(calls, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Expected calls %v, got %v", tt.want, calls)
+			}
+			if tt.mode != SubscriptionKeep && !cacheService.IsThreadUnsubscribed("1") {
+				t.Error("Expected thread 1 to be recorded as unsubscribed")
+			}
+		})
+	}
+}
+
+func TestNotificationService_ApplySubscriptionFailure(t *testing.T) {
+	unsubscribeErr := errors.New("API error")
+	notificationRepo := &mockNotificationRepo{
+		deleteFunc:      func(id string) error { return nil },
+		unsubscribeFunc: func(id string) error { return unsubscribeErr },
+	}
+
+	cacheService := newMockCacheService()
+	service := NewNotificationService(notificationRepo, &mockPRService{}, &mockIssueService{}, cacheService, WithSubscription(SubscriptionUnsubscribe))
+	plan := &Plan{Decisions: []Decision{{ThreadID: "1", Repository: "o/r", Action: ActionDone}}}
+
+	if err := service.Apply(testr.New(t), plan); !errors.Is(err, ErrClearFailed) {
+		t.Fatalf("Expected ErrClearFailed when the subscription cannot be updated, got %v", err)
+	}
+	if plan.Summary.Failed != 1 || plan.Summary.Cleared != 0 {
+		t.Errorf("Expected the thread to count as failed, got %+v", plan.Summary.Counts)
+	}
+	if cacheService.IsThreadDeleted("1", time.Time{}) {
+		t.Fatal("Expected the thread not to be recorded as cleared, so the next run retries it")
+	}
+
+	unsubscribeErr = nil
+	plan = &Plan{Decisions: []Decision{{ThreadID: "1", Repository: "o/r", Action: ActionDone}}}
+	if err := service.Apply(testr.New(t), plan); err != nil {
+		t.Fatalf("Unexpected error on retry: %v", err)
+	}
+	if !cacheService.IsThreadDeleted("1", time.Time{}) || !cacheService.IsThreadUnsubscribed("1") {
+		t.Error("Expected the retry to clear and unsubscribe the thread")
+	}
+}
+
+func TestNotificationService_Concurrency(t *testing.T) {
+	const count = 50
+
+	var notifications []Notification
+	for i := range count {
+		id := fmt.Sprint(i)
+		notifications = append(notifications, Notification{ID: id, Subject: Subject{Type: "PullRequest", URL: "pr" + id}})
+	}
+
+	var inFlight, maxInFlight atomic.Int32
+	track := func() func() {
+		n := inFlight.Add(1)
+		for {
+			m := maxInFlight.Load()
+			if n <= m || maxInFlight.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		return func() { inFlight.Add(-1) }
+	}
+
+	notificationRepo := &mockNotificationRepo{
+		getByTimePeriodFunc: func(since string) ([]Notification, error) {
+			return notifications, nil
+		},
+		deleteFunc: func(id string) error {
+			defer track()()
+			return nil
+		},
+	}
+
+	prService := &mockPRService{
+		getPRStatusFunc: func(url string) (PRStatus, error) {
+			defer track()()
+			return PRStatus{State: PRStateMerged}, nil
+		},
+	}
+
+	cacheService := newMockCacheService()
+	service := NewNotificationService(notificationRepo, prService, &mockIssueService{}, cacheService, WithConcurrency(4))
+
+	plan, err := service.Plan(testr.New(t), "7d", false)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(plan.Decisions) != count {
+		t.Fatalf("Expected %d decisions, got %d", count, len(plan.Decisions))
+	}
+	for i, d := range plan.Decisions {
+		if d.ThreadID != fmt.Sprint(i) {
+			t.Fatalf("Expected decisions in notification order, got %s at %d", d.ThreadID, i)
+		}
+	}
+
+	if err := service.Apply(testr.New(t), plan); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, n := range notifications {
+		if !cacheService.IsThreadDeleted(n.ID, time.Time{}) {
+			t.Errorf("Expected thread %s to be marked as deleted", n.ID)
+		}
+	}
+
+	if got := maxInFlight.Load(); got > 4 {
+		t.Errorf("Expected at most 4 concurrent calls, got %d", got)
+	}
+	if got := maxInFlight.Load(); got < 2 {
+		t.Errorf("Expected calls to run concurrently, got at most %d at once", got)
+	}
+}
+
+type mockBatchPRService struct {
+	mockPRService
+	getPRStatusesFunc func(urls []string) (map[string]PRStatus, error)
+}
+
+func (m *mockBatchPRService) GetPRStatuses(urls []string) (map[string]PRStatus, error) {
+	return m.getPRStatusesFunc(urls)
+}
+
+func TestNotificationService_PrefetchesPRStatuses(t *testing.T) {
+	notificationRepo := &mockNotificationRepo{
+		getByTimePeriodFunc: func(since string) ([]Notification, error) {
+			return []Notification{
+				{ID: "1", Subject: Subject{Type: "PullRequest", URL: "pr1"}},
+				{ID: "2", Subject: Subject{Type: "PullRequest", URL: "pr2"}},
+				{ID: "3", Subject: Subject{Type: "PullRequest", URL: "pr3"}},
+				{ID: "4", Subject: Subject{Type: "Issue", URL: "issue4"}},
+			}, nil
+		},
+	}
+
+	var single []string
+	prService := &mockBatchPRService{
+		mockPRService: mockPRService{
+			getPRStatusFunc: func(url string) (PRStatus, error) {
+				single = append(single, url)
+				return PRStatus{State: PRStateMerged}, nil
+			},
+		},
+		getPRStatusesFunc: func(urls []string) (map[string]PRStatus, error) {
+			if strings.Join(urls, ",") != "pr1,pr2" {
+				t.Errorf("Expected batch of pr1,pr2, got %v", urls)
+			}
+			return map[string]PRStatus{"pr1": {State: PRStateMerged}}, errors.New("pr2 not found")
+		},
+	}
+
+	issueService := &mockIssueService{
+		getIssueStatusFunc: func(url string) (IssueStatus, error) {
+			return IssueStatus{}, nil
+		},
+	}
+
+	cacheService := newMockCacheService()
+	cacheService.SetThreadDeleted("3", time.Time{})
+
+	service := NewNotificationService(notificationRepo, prService, issueService, cacheService)
+	plan, err := service.Plan(testr.New(t), "7d", false)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(plan.Decisions) != 2 {
+		t.Errorf("Expected 2 decisions, got %d", len(plan.Decisions))
+	}
+	if strings.Join(single, ",") != "pr2" {
+		t.Errorf("Expected only pr2 to fall back to a single lookup, got %v", single)
+	}
+	if status, ok := cacheService.GetPRStatus("pr1"); !ok || status.FetchedAt.IsZero() {
+		t.Errorf("Expected prefetched pr1 to be cached with a fetch time, got %+v", status)
+	}
+}
+
+func TestNotificationService_SinceAuto(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	lastSweep := now.Add(-time.Hour)
+
+	tests := []struct {
+		name          string
+		lastSweep     time.Time
+		fetchErr      error
+		prErr         error
+		deleteErr     error
+		filter        Filter
+		narrowed      bool
+		wantErr       error
+		wantSince     string
+		wantLastSweep time.Time
+	}{
+		{
+			name:          "first run uses the default window",
+			wantSince:     defaultSince,
+			wantLastSweep: now,
+		},
+		{
+			name:          "later runs start from the watermark with overlap",
+			lastSweep:     lastSweep,
+			wantSince:     "2024-06-01T10:50:00Z",
+			wantLastSweep: now,
+		},
+		{
+			name:          "truncated fetch keeps the watermark",
+			lastSweep:     lastSweep,
+			fetchErr:      ErrTruncated,
+			wantErr:       ErrIncomplete,
+			wantSince:     "2024-06-01T10:50:00Z",
+			wantLastSweep: lastSweep,
+		},
+		{
+			name:          "failed lookup keeps the watermark",
+			lastSweep:     lastSweep,
+			prErr:         errors.New("API error"),
+			wantErr:       ErrIncomplete,
+			wantSince:     "2024-06-01T10:50:00Z",
+			wantLastSweep: lastSweep,
+		},
+		{
+			name:          "failed clear keeps the watermark",
+			lastSweep:     lastSweep,
+			deleteErr:     errors.New("delete failed"),
+			wantErr:       ErrClearFailed,
+			wantSince:     "2024-06-01T10:50:00Z",
+			wantLastSweep: lastSweep,
+		},
+		{
+			name:          "filtered sweep keeps the watermark",
+			lastSweep:     lastSweep,
+			filter:        Filter{ExcludeRepositories: []string{"myorg/docs"}},
+			wantSince:     "2024-06-01T10:50:00Z",
+			wantLastSweep: lastSweep,
+		},
+		{
+			name:          "unread only sweep keeps the watermark",
+			lastSweep:     lastSweep,
+			narrowed:      true,
+			wantSince:     "2024-06-01T10:50:00Z",
+			wantLastSweep: lastSweep,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotSince string
+			notificationRepo := &mockNotificationRepo{
+				getByTimePeriodFunc: func(since string) ([]Notification, error) {
+					gotSince = since
+					return []Notification{
+						{ID: "1", Subject: Subject{Type: "PullRequest", URL: "pr1"}},
+						{ID: "2", Subject: Subject{Type: "PullRequest", URL: "pr2"}},
+					}, tt.fetchErr
+				},
+				deleteFunc: func(id string) error { return tt.deleteErr },
+				narrowed:   tt.narrowed,
+			}
+			prService := &mockPRService{
+				getPRStatusFunc: func(url string) (PRStatus, error) {
+					if url == "pr2" && tt.prErr != nil {
+						return PRStatus{}, tt.prErr
+					}
+					return PRStatus{State: PRStateMerged}, nil
+				},
+			}
+
+			cacheService := newMockCacheService()
+			cacheService.cache.LastSweep = tt.lastSweep
+			service := NewNotificationService(notificationRepo, prService, &mockIssueService{}, cacheService, WithFilter(tt.filter))
+			service.(*notificationService).now = func() time.Time { return now }
+
+			if _, err := service.FetchNotifications(testr.New(t), SinceAuto, false); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+
+			if gotSince != tt.wantSince {
+				t.Errorf("Expected since %q, got %q", tt.wantSince, gotSince)
+			}
+			if got := cacheService.GetLastSweep(); !got.Equal(tt.wantLastSweep) {
+				t.Errorf("Expected last sweep %v, got %v", tt.wantLastSweep, got)
+			}
+		})
+	}
+}
+
+func TestNotificationService_FixedWindowKeepsWatermark(t *testing.T) {
+	notificationRepo := &mockNotificationRepo{
+		getByTimePeriodFunc: func(since string) ([]Notification, error) { return nil, nil },
+	}
+
+	cacheService := newMockCacheService()
+	service := NewNotificationService(notificationRepo, &mockPRService{}, &mockIssueService{}, cacheService)
+
+	if _, err := service.FetchNotifications(testr.New(t), "7d", false); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := cacheService.GetLastSweep(); !got.IsZero() {
+		t.Errorf("Expected no watermark outside auto mode, got %v", got)
+	}
+	if got := cacheService.GetMaxWindow(); got != 7*day {
+		t.Errorf("Expected the 7d window to be recorded, got %v", got)
+	}
+}
+
+func TestNotificationService_ReevaluatesThreadsWithNewActivity(t *testing.T) {
+	clearedAt := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	revertedAt := clearedAt.Add(48 * time.Hour)
+
+	notificationRepo := &mockNotificationRepo{
+		getByTimePeriodFunc: func(since string) ([]Notification, error) {
+			return []Notification{
+				{ID: "1", UpdatedAt: clearedAt, Subject: Subject{Type: "PullRequest", URL: "pr1"}},
+				{ID: "2", UpdatedAt: revertedAt, Subject: Subject{Type: "PullRequest", URL: "pr2", Title: "Revert discussion"}},
+			}, nil
+		},
+		deleteFunc: func(id string) error { return nil },
+	}
+	prService := &mockPRService{
+		getPRStatusFunc: func(url string) (PRStatus, error) {
+			return PRStatus{State: PRStateMerged}, nil
+		},
+	}
+
+	cacheService := newMockCacheService()
+	cacheService.SetThreadDeleted("1", clearedAt)
+	cacheService.SetThreadDeleted("2", clearedAt)
+	// Unlike the default rules, these do not keep reactivated threads.
+	rules, err := NewRuleSet([]Rule{{Match: RuleMatch{PRState: []string{PRStateMerged}}, Action: ActionDone}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	service := NewNotificationService(notificationRepo, prService, &mockIssueService{}, cacheService, WithRules(rules))
+
+	plan, err := service.Plan(testr.New(t), "7d", false)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(plan.Decisions) != 1 || plan.Decisions[0].ThreadID != "2" {
+		t.Fatalf("Expected only thread 2 to be evaluated again, got %+v", plan.Decisions)
+	}
+	if !plan.Decisions[0].UpdatedAt.Equal(revertedAt) {
+		t.Errorf("Expected the decision to carry updated_at %v, got %v", revertedAt, plan.Decisions[0].UpdatedAt)
+	}
+
+	if err := service.Apply(testr.New(t), plan); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !cacheService.IsThreadDeleted("2", revertedAt) {
+		t.Error("Expected thread 2 to be recorded as cleared at its new updated_at")
+	}
+}
+
+func TestNotificationService_DefaultRulesKeepReactivatedThreads(t *testing.T) {
+	clearedAt := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	notificationRepo := &mockNotificationRepo{
+		getByTimePeriodFunc: func(since string) ([]Notification, error) {
+			return []Notification{
+				{ID: "1", UpdatedAt: clearedAt.Add(48 * time.Hour), Subject: Subject{Type: "PullRequest", URL: "pr1", Title: "Revert discussion"}},
+				{ID: "2", UpdatedAt: clearedAt, Subject: Subject{Type: "PullRequest", URL: "pr2"}},
+			}, nil
+		},
+	}
+	prService := &mockPRService{
+		getPRStatusFunc: func(url string) (PRStatus, error) {
+			return PRStatus{State: PRStateMerged}, nil
+		},
+	}
+
+	cacheService := newMockCacheService()
+	cacheService.SetThreadDeleted("1", clearedAt)
+	service := NewNotificationService(notificationRepo, prService, &mockIssueService{}, cacheService)
+
+	plan, err := service.Plan(testr.New(t), "7d", false)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(plan.Decisions) != 1 || plan.Decisions[0].ThreadID != "2" {
+		t.Errorf("Expected the reactivated merged PR to be kept and only thread 2 cleared, got %+v", plan.Decisions)
+	}
+}
+
+func TestNotificationService_RecordsFailedRuns(t *testing.T) {
+	notificationRepo := &mockNotificationRepo{
+		getByTimePeriodFunc: func(since string) ([]Notification, error) {
+			return nil, errors.New("API rate limit exceeded")
+		},
+	}
+
+	cacheService := NewSQLiteCacheService(t.TempDir())
+	t.Cleanup(func() { cacheService.Close() })
+	service := NewNotificationService(notificationRepo, &mockPRService{}, &mockIssueService{}, cacheService)
+
+	if _, err := service.FetchNotifications(testr.New(t), "7d", false); err == nil {
+		t.Fatal("Expected the fetch error")
+	}
+
+	runs, err := cacheService.(RunHistory).Runs(10)
+	if err != nil {
+		t.Fatalf("Failed to read runs: %v", err)
+	}
+	if len(runs) != 1 || runs[0].Since != "7d" || !strings.Contains(runs[0].Error, "API rate limit exceeded") {
+		t.Errorf("Expected the failed run to be recorded with its error, got %+v", runs)
+	}
+}
+
+func TestNotificationService_FilterSkipsLookups(t *testing.T) {
+	notificationRepo := &mockNotificationRepo{
+		getByTimePeriodFunc: func(since string) ([]Notification, error) {
+			return []Notification{
+				{ID: "1", Subject: Subject{Type: "PullRequest", URL: "pr1"}, Repository: Repository{FullName: "myorg/app"}},
+				{ID: "2", Subject: Subject{Type: "PullRequest", URL: "pr2"}, Repository: Repository{FullName: "other/app"}},
+			}, nil
+		},
+	}
+
+	var looked []string
+	prService := &mockPRService{
+		getPRStatusFunc: func(url string) (PRStatus, error) {
+			looked = append(looked, url)
+			return PRStatus{State: PRStateMerged}, nil
+		},
+	}
+
+	service := NewNotificationService(notificationRepo, prService, &mockIssueService{}, newMockCacheService(),
+		WithFilter(Filter{Repositories: []string{"myorg/*"}}))
+
+	plan, err := service.Plan(testr.New(t), "7d", false)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(plan.Decisions) != 1 || plan.Decisions[0].ThreadID != "1" {
+		t.Errorf("Expected only thread 1 to be cleared, got %+v", plan.Decisions)
+	}
+	if len(looked) != 1 || looked[0] != "pr1" {
+		t.Errorf("Expected only pr1 to be looked up, got %v", looked)
+	}
+}
+
+func TestNotificationService_Summary(t *testing.T) {
+	notificationRepo := &mockNotificationRepo{
+		getByTimePeriodFunc: func(since string) ([]Notification, error) {
+			return []Notification{
+				{ID: "1", Subject: Subject{Type: "PullRequest", URL: "merged"}, Repository: Repository{FullName: "org/a"}},
+				{ID: "2", Subject: Subject{Type: "PullRequest", URL: "open"}, Repository: Repository{FullName: "org/a"}},
+				{ID: "3", Subject: Subject{Type: "PullRequest", URL: "broken"}, Repository: Repository{FullName: "org/b"}},
+				{ID: "4", Subject: Subject{Type: "PullRequest", URL: "merged"}, Repository: Repository{FullName: "org/b"}},
+				{ID: "5", Subject: Subject{Type: "PullRequest", URL: "merged"}, Repository: Repository{FullName: "other/c"}},
+			}, nil
+		},
+		deleteFunc: func(id string) error { return nil },
+	}
+	prService := &mockPRService{
+		getPRStatusFunc: func(url string) (PRStatus, error) {
+			switch url {
+			case "merged":
+				return PRStatus{State: PRStateMerged}, nil
+			case "broken":
+				return PRStatus{}, errors.New("API error")
+			default:
+				return PRStatus{State: PRStateOpen}, nil
+			}
+		},
+	}
+
+	cacheService := newMockCacheService()
+	cacheService.SetThreadDeleted("4", time.Time{})
+	service := NewNotificationService(notificationRepo, prService, &mockIssueService{}, cacheService,
+		WithFilter(Filter{ExcludeRepositories: []string{"other/*"}}))
+
+	summary, err := service.FetchNotifications(testr.New(t), "7d", false)
+	if !errors.Is(err, ErrIncomplete) {
+		t.Fatalf("Expected ErrIncomplete for the notification that could not be evaluated, got %v", err)
+	}
+
+	want := Counts{Fetched: 5, Filtered: 1, Skipped: 1, Checked: 3, Cleared: 1, Failed: 1}
+	if summary.Counts != want {
+		t.Errorf("Expected totals %+v, got %+v", want, summary.Counts)
+	}
+	wantA := Counts{Fetched: 2, Checked: 2, Cleared: 1}
+	if got := summary.Repositories["org/a"]; got != wantA {
+		t.Errorf("Expected org/a %+v, got %+v", wantA, got)
+	}
+	if names := summary.RepositoryNames(); len(names) != 3 || names[0] != "org/a" || names[2] != "other/c" {
+		t.Errorf("Expected sorted repositories, got %v", names)
+	}
 }
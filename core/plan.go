@@ -0,0 +1,77 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Plan is the set of decisions computed for a sweep, which can be reviewed
+// and then applied later.
+type Plan struct {
+	CreatedAt time.Time `json:"created_at"`
+	Since     string    `json:"since"`
+	// Watermark is when the fetch for a --since auto sweep started. It is
+	// only set when every notification in the window was fetched and
+	// evaluated, and is recorded once the plan has been applied in full.
+	Watermark time.Time `json:"watermark,omitzero"`
+	// Truncated is set when the fetch stopped at the page limit, and
+	// Unevaluated counts the notifications the rules failed for.
+	Truncated   bool       `json:"truncated,omitempty"`
+	Unevaluated int        `json:"unevaluated,omitempty"`
+	Decisions   []Decision `json:"decisions"`
+	Summary     Summary    `json:"summary"`
+}
+
+// Incomplete returns ErrIncomplete if the plan does not cover every
+// notification in its window, and nil otherwise.
+func (p *Plan) Incomplete() error {
+	switch {
+	case p.Truncated && p.Unevaluated > 0:
+		return fmt.Errorf("%w: stopped at the page limit and %d could not be evaluated", ErrIncomplete, p.Unevaluated)
+	case p.Truncated:
+		return fmt.Errorf("%w: stopped at the page limit", ErrIncomplete)
+	case p.Unevaluated > 0:
+		return fmt.Errorf("%w: %d could not be evaluated", ErrIncomplete, p.Unevaluated)
+	}
+	return nil
+}
+
+// Decision records what should happen to a single notification thread and why.
+type Decision struct {
+	ThreadID string `json:"thread_id"`
+	Title    string `json:"title"`
+	Type     string `json:"type"`
+	URL      string `json:"url"`
+	// Repository and NotificationReason identify the thread in logs; Reason
+	// is the rule that made the decision.
+	Repository         string `json:"repository,omitempty"`
+	NotificationReason string `json:"notification_reason,omitempty"`
+	// UpdatedAt is the thread's updated_at when it was evaluated, recorded
+	// on clearing so that later activity brings the thread back.
+	UpdatedAt time.Time `json:"updated_at,omitzero"`
+	Action    string    `json:"action"`
+	Reason    string    `json:"reason"`
+}
+
+func WritePlan(path string, plan *Plan) error {
+	data, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+func ReadPlan(path string) (*Plan, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var plan Plan
+	if err := json.Unmarshal(data, &plan); err != nil {
+		return nil, fmt.Errorf("invalid plan file %s: %w", path, err)
+	}
+	return &plan, nil
+}
@@ -0,0 +1,69 @@
+package core
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWriteAndReadPlan(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "plan.json")
+	plan := &Plan{
+		CreatedAt: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Since:     "7d",
+		Decisions: []Decision{
+			{ThreadID: "1", Title: "PR 1", Type: "PullRequest", URL: "pr1", Action: ActionDone, Reason: "pull request merged"},
+		},
+	}
+
+	if err := WritePlan(path, plan); err != nil {
+		t.Fatalf("Failed to write plan: %v", err)
+	}
+
+	loaded, err := ReadPlan(path)
+	if err != nil {
+		t.Fatalf("Failed to read plan: %v", err)
+	}
+
+	if !loaded.CreatedAt.Equal(plan.CreatedAt) || loaded.Since != plan.Since {
+		t.Errorf("Expected plan header %v/%s, got %v/%s", plan.CreatedAt, plan.Since, loaded.CreatedAt, loaded.Since)
+	}
+	if len(loaded.Decisions) != 1 || loaded.Decisions[0] != plan.Decisions[0] {
+		t.Errorf("Expected decisions %+v, got %+v", plan.Decisions, loaded.Decisions)
+	}
+}
+
+func TestReadPlan_Invalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "plan.json")
+	if err := os.WriteFile(path, []byte("not json"), 0o644); err != nil {
+		t.Fatalf("Failed to write plan: %v", err)
+	}
+
+	if _, err := ReadPlan(path); err == nil {
+		t.Error("Expected error for invalid plan file, got nil")
+	}
+}
+
+func TestPlan_Incomplete(t *testing.T) {
+	tests := []struct {
+		name string
+		plan Plan
+		want error
+	}{
+		{"complete", Plan{}, nil},
+		{"truncated", Plan{Truncated: true}, ErrIncomplete},
+		{"unevaluated", Plan{Unevaluated: 2}, ErrIncomplete},
+		// Notifications that failed to clear are reported by Apply instead.
+		{"clear failures", Plan{Summary: Summary{Counts: Counts{Failed: 1}}}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.plan.Incomplete(); !errors.Is(err, tt.want) {
+				t.Errorf("Incomplete() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -1,12 +1,68 @@
 package core
 
+import "time"
+
+const (
+	PRStateOpen   = "open"
+	PRStateDraft  = "draft"
+	PRStateMerged = "merged"
+	PRStateClosed = "closed"
+)
+
 type PullRequest struct {
-	Merged bool   `json:"merged"`
-	Title  string `json:"title"`
+	State    string     `json:"state"`
+	Draft    bool       `json:"draft"`
+	Merged   bool       `json:"merged"`
+	MergedAt *time.Time `json:"merged_at"`
+	ClosedAt *time.Time `json:"closed_at"`
+	Title    string     `json:"title"`
+	User     User       `json:"user"`
+}
+
+type User struct {
+	Login string `json:"login"`
+}
+
+// PRStatus is what dailyare knows about a pull request, along with when it
+// was fetched so that cached copies can be refreshed.
+type PRStatus struct {
+	// State is one of the PRState constants; closed means closed without
+	// being merged.
+	State     string     `json:"state"`
+	Author    string     `json:"author,omitempty"`
+	MergedAt  *time.Time `json:"merged_at,omitempty"`
+	ClosedAt  *time.Time `json:"closed_at,omitempty"`
+	FetchedAt time.Time  `json:"fetched_at"`
+	// UsedAt is when the cache last stored or returned the status.
+	UsedAt time.Time `json:"used_at,omitzero"`
+}
+
+func newPRStatus(pr PullRequest) PRStatus {
+	status := PRStatus{
+		State:    PRStateOpen,
+		Author:   pr.User.Login,
+		MergedAt: pr.MergedAt,
+		ClosedAt: pr.ClosedAt,
+	}
+	switch {
+	case pr.Merged:
+		status.State = PRStateMerged
+	case pr.State == PRStateClosed:
+		status.State = PRStateClosed
+	case pr.Draft:
+		status.State = PRStateDraft
+	}
+	return status
+}
+
+// IsTerminal reports whether the status is cached permanently. Closed PRs
+// can be reopened, but that is rare enough not to be worth re-checking.
+func (s PRStatus) IsTerminal() bool {
+	return s.State == PRStateMerged || s.State == PRStateClosed
 }
 
 type PRService interface {
-	GetPRStatus(url string) (bool, error)
+	GetPRStatus(url string) (PRStatus, error)
 }
 
 type githubPRService struct {
@@ -17,12 +73,12 @@ func NewGithubPRService(client GithubClient) PRService {
 	return &githubPRService{client: client}
 }
 
-func (s *githubPRService) GetPRStatus(url string) (bool, error) {
+func (s *githubPRService) GetPRStatus(url string) (PRStatus, error) {
 	apiURL := formatGithubURL(url)
 	var pr PullRequest
 	err := s.client.Get(apiURL, &pr)
 	if err != nil {
-		return false, err
+		return PRStatus{}, err
 	}
-	return pr.Merged, nil
+	return newPRStatus(pr), nil
 }
@@ -3,15 +3,19 @@ package core
 import (
 	"errors"
 	"testing"
+	"time"
 )
 
 func TestGithubPRService_GetPRStatus(t *testing.T) {
+	closedAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
 	tests := []struct {
 		name       string
 		url        string
 		mockResp   PullRequest
 		mockErr    error
-		wantMerged bool
+		wantState  string
+		wantAuthor string
 		wantErr    bool
 	}{
 		{
@@ -20,8 +24,10 @@ func TestGithubPRService_GetPRStatus(t *testing.T) {
 			mockResp: PullRequest{
 				Merged: true,
 				Title:  "Test PR",
+				User:   User{Login: "octocat"},
 			},
-			wantMerged: true,
+			wantState:  PRStateMerged,
+			wantAuthor: "octocat",
 		},
 		{
 			name: "Unmerged PR",
@@ -30,7 +36,25 @@ func TestGithubPRService_GetPRStatus(t *testing.T) {
 				Merged: false,
 				Title:  "Test PR",
 			},
-			wantMerged: false,
+			wantState: PRStateOpen,
+		},
+		{
+			name: "Draft PR",
+			url:  "https://api.github.com/repos/owner/repo/pulls/4",
+			mockResp: PullRequest{
+				State: "open",
+				Draft: true,
+			},
+			wantState: PRStateDraft,
+		},
+		{
+			name: "Closed without merging",
+			url:  "https://api.github.com/repos/owner/repo/pulls/5",
+			mockResp: PullRequest{
+				State:    "closed",
+				ClosedAt: &closedAt,
+			},
+			wantState: PRStateClosed,
 		},
 		{
 			name:    "API Error",
@@ -54,14 +78,17 @@ func TestGithubPRService_GetPRStatus(t *testing.T) {
 			}
 
 			service := NewGithubPRService(client)
-			merged, err := service.GetPRStatus(tt.url)
+			status, err := service.GetPRStatus(tt.url)
 
 			if (err != nil) != tt.wantErr {
 				t.Errorf("GetPRStatus() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
-			if err == nil && merged != tt.wantMerged {
-				t.Errorf("GetPRStatus() = %v, want %v", merged, tt.wantMerged)
+			if err == nil && status.State != tt.wantState {
+				t.Errorf("GetPRStatus() state = %q, want %q", status.State, tt.wantState)
+			}
+			if err == nil && status.Author != tt.wantAuthor {
+				t.Errorf("GetPRStatus() author = %q, want %q", status.Author, tt.wantAuthor)
 			}
 		})
 	}
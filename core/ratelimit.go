@@ -0,0 +1,244 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+)
+
+// secondaryRateLimitWait is how long GitHub asks clients to back off after a
+// secondary rate limit that does not say when to retry.
+const secondaryRateLimitWait = time.Minute
+
+// RetryPolicy controls how the rate limit transport waits and retries.
+type RetryPolicy struct {
+	// MaxRetries is how many times a request is retried after a rate limit
+	// or, for idempotent requests, a server error.
+	MaxRetries int
+	// BaseDelay and MaxDelay bound the exponential backoff after server errors.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// MaxWait is the longest dailyare sleeps for a rate limit to reset. Longer
+	// waits abort with a RateLimitError instead.
+	MaxWait time.Duration
+}
+
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxRetries: 3,
+		BaseDelay:  time.Second,
+		MaxDelay:   30 * time.Second,
+		MaxWait:    5 * time.Minute,
+	}
+}
+
+// RateLimitError is returned instead of sending a request when the rate limit
+// will not reset within the policy's MaxWait.
+type RateLimitError struct {
+	Reset time.Time
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("rate limit exceeded until %s", e.Reset.Format(time.RFC3339))
+}
+
+type rateLimitTransport struct {
+	base   http.RoundTripper
+	policy RetryPolicy
+	logger logr.Logger
+
+	mu        sync.Mutex
+	remaining int
+	reset     time.Time
+
+	now   func() time.Time
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// NewRateLimitTransport wraps base so that requests respect GitHub's rate
+// limit headers and transient failures are retried with backoff.
+func NewRateLimitTransport(base http.RoundTripper, policy RetryPolicy, logger logr.Logger) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &rateLimitTransport{
+		base:      base,
+		policy:    policy,
+		logger:    logger,
+		remaining: -1,
+		now:       time.Now,
+		sleep:     sleepContext,
+	}
+}
+
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.waitForReset(req.Context()); err != nil {
+		return nil, err
+	}
+
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			body, err := rewindBody(req)
+			if err != nil {
+				return nil, err
+			}
+			req = body
+		}
+
+		resp, err := t.base.RoundTrip(req)
+		if err != nil {
+			return nil, err
+		}
+		t.record(resp.Header)
+
+		wait, retry := t.retryAfter(req, resp, attempt)
+		if !retry {
+			return resp, nil
+		}
+		if wait > t.policy.MaxWait {
+			return resp, nil
+		}
+
+		t.logger.V(1).Info("Retrying request",
+			"method", req.Method,
+			"url", req.URL.String(),
+			"status", resp.StatusCode,
+			"attempt", attempt+1,
+			"wait", wait.String())
+		resp.Body.Close()
+
+		if err := t.sleep(req.Context(), wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// waitForReset sleeps until the rate limit resets when the last response
+// said none was left, or aborts if that is too far away.
+func (t *rateLimitTransport) waitForReset(ctx context.Context) error {
+	t.mu.Lock()
+	remaining, reset := t.remaining, t.reset
+	t.mu.Unlock()
+
+	if remaining != 0 {
+		return nil
+	}
+	wait := reset.Sub(t.now())
+	if wait <= 0 {
+		return nil
+	}
+	if wait > t.policy.MaxWait {
+		return &RateLimitError{Reset: reset}
+	}
+
+	t.logger.V(1).Info("Rate limit exhausted, waiting for reset", "wait", wait.String())
+	return t.sleep(ctx, wait)
+}
+
+func (t *rateLimitTransport) record(header http.Header) {
+	remaining, err := strconv.Atoi(header.Get("X-RateLimit-Remaining"))
+	if err != nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.remaining = remaining
+	if reset, err := strconv.ParseInt(header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+		t.reset = time.Unix(reset, 0)
+	}
+}
+
+// retryAfter decides whether a response should be retried and how long to
+// wait first.
+func (t *rateLimitTransport) retryAfter(req *http.Request, resp *http.Response, attempt int) (time.Duration, bool) {
+	if attempt >= t.policy.MaxRetries {
+		return 0, false
+	}
+
+	if isRateLimited(resp) {
+		// Rate limited requests were never processed, so any method is
+		// safe to send again.
+		return t.rateLimitWait(resp.Header), true
+	}
+
+	if resp.StatusCode >= 500 && isIdempotent(req.Method) {
+		return t.backoff(attempt), true
+	}
+	return 0, false
+}
+
+func (t *rateLimitTransport) rateLimitWait(header http.Header) time.Duration {
+	if seconds, err := strconv.Atoi(header.Get("Retry-After")); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if header.Get("X-RateLimit-Remaining") == "0" {
+		if reset, err := strconv.ParseInt(header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+			return max(time.Unix(reset, 0).Sub(t.now()), 0)
+		}
+	}
+	return secondaryRateLimitWait
+}
+
+// backoff returns an exponential delay with full jitter.
+func (t *rateLimitTransport) backoff(attempt int) time.Duration {
+	delay := min(t.policy.BaseDelay<<attempt, t.policy.MaxDelay)
+	if delay <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int64N(int64(delay))) + 1
+}
+
+func isRateLimited(resp *http.Response) bool {
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests:
+		return true
+	case http.StatusForbidden:
+		return resp.Header.Get("Retry-After") != "" || resp.Header.Get("X-RateLimit-Remaining") == "0"
+	default:
+		return false
+	}
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
+
+// rewindBody returns a copy of req with a fresh body so it can be sent again.
+func rewindBody(req *http.Request) (*http.Request, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return req, nil
+	}
+	if req.GetBody == nil {
+		return nil, fmt.Errorf("cannot retry %s %s: request body cannot be replayed", req.Method, req.URL)
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	clone := req.Clone(req.Context())
+	clone.Body = body
+	return clone, nil
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
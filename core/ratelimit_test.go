@@ -0,0 +1,216 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr/testr"
+)
+
+func newTestTransport(t *testing.T, policy RetryPolicy, now time.Time) (*rateLimitTransport, *[]time.Duration) {
+	var slept []time.Duration
+	transport := NewRateLimitTransport(http.DefaultTransport, policy, testr.New(t)).(*rateLimitTransport)
+	transport.now = func() time.Time { return now }
+	transport.sleep = func(ctx context.Context, d time.Duration) error {
+		slept = append(slept, d)
+		return nil
+	}
+	return transport, &slept
+}
+
+// cannedServer replies with each handler in turn, repeating the last one.
+func cannedServer(t *testing.T, calls *atomic.Int32, handlers ...http.HandlerFunc) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(calls.Add(1)) - 1
+		handlers[min(n, len(handlers)-1)](w, r)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func okHandler(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	w.Header().Set("X-RateLimit-Remaining", "4999")
+	_, _ = w.Write(append([]byte("ok:"), body...))
+}
+
+func TestRateLimitTransport_RetriesServerErrors(t *testing.T) {
+	var calls atomic.Int32
+	server := cannedServer(t, &calls,
+		func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusBadGateway) },
+		func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusServiceUnavailable) },
+		okHandler,
+	)
+
+	transport, slept := newTestTransport(t, DefaultRetryPolicy(), time.Now())
+	client := &http.Client{Transport: transport}
+
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected 200 after retries, got %d", resp.StatusCode)
+	}
+	if calls.Load() != 3 {
+		t.Errorf("Expected 3 requests, got %d", calls.Load())
+	}
+	if len(*slept) != 2 {
+		t.Fatalf("Expected 2 backoffs, got %v", *slept)
+	}
+	for i, d := range *slept {
+		if limit := time.Second << i; d <= 0 || d > limit {
+			t.Errorf("Expected backoff %d within (0, %v], got %v", i, limit, d)
+		}
+	}
+}
+
+func TestRateLimitTransport_DoesNotRetryNonIdempotentServerErrors(t *testing.T) {
+	var calls atomic.Int32
+	server := cannedServer(t, &calls,
+		func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusBadGateway) },
+	)
+
+	transport, _ := newTestTransport(t, DefaultRetryPolicy(), time.Now())
+	client := &http.Client{Transport: transport}
+
+	resp, err := client.Post(server.URL, "application/json", strings.NewReader("{}"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusBadGateway || calls.Load() != 1 {
+		t.Errorf("Expected a single 502, got %d after %d requests", resp.StatusCode, calls.Load())
+	}
+}
+
+func TestRateLimitTransport_GivesUpAfterMaxRetries(t *testing.T) {
+	var calls atomic.Int32
+	server := cannedServer(t, &calls,
+		func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusInternalServerError) },
+	)
+
+	policy := DefaultRetryPolicy()
+	policy.MaxRetries = 2
+	transport, _ := newTestTransport(t, policy, time.Now())
+	client := &http.Client{Transport: transport}
+
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusInternalServerError || calls.Load() != 3 {
+		t.Errorf("Expected 500 after 3 requests, got %d after %d", resp.StatusCode, calls.Load())
+	}
+}
+
+func TestRateLimitTransport_SecondaryRateLimit(t *testing.T) {
+	var calls atomic.Int32
+	server := cannedServer(t, &calls,
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Retry-After", "30")
+			w.WriteHeader(http.StatusForbidden)
+		},
+		okHandler,
+	)
+
+	transport, slept := newTestTransport(t, DefaultRetryPolicy(), time.Now())
+	client := &http.Client{Transport: transport}
+
+	// Rate limited requests are retried whatever the method, with their body.
+	resp, err := client.Post(server.URL, "application/json", strings.NewReader(`{"q":1}`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != `ok:{"q":1}` {
+		t.Errorf("Expected the body to be replayed, got %q", body)
+	}
+	if len(*slept) != 1 || (*slept)[0] != 30*time.Second {
+		t.Errorf("Expected a single 30s wait, got %v", *slept)
+	}
+}
+
+func TestRateLimitTransport_PrimaryRateLimit(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	reset := now.Add(90 * time.Second)
+
+	var calls atomic.Int32
+	server := cannedServer(t, &calls,
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+			w.WriteHeader(http.StatusForbidden)
+		},
+		okHandler,
+	)
+
+	transport, slept := newTestTransport(t, DefaultRetryPolicy(), now)
+	client := &http.Client{Transport: transport}
+
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected 200 after waiting for reset, got %d", resp.StatusCode)
+	}
+	if len(*slept) != 1 || (*slept)[0] != 90*time.Second {
+		t.Errorf("Expected a single 90s wait, got %v", *slept)
+	}
+}
+
+func TestRateLimitTransport_AbortsWhenResetTooFar(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	reset := now.Add(time.Hour)
+
+	var calls atomic.Int32
+	server := cannedServer(t, &calls,
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+			_, _ = w.Write([]byte("last one"))
+		},
+	)
+
+	transport, slept := newTestTransport(t, DefaultRetryPolicy(), now)
+	client := &http.Client{Transport: transport}
+
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Unexpected error on the last allowed request: %v", err)
+	}
+	resp.Body.Close()
+
+	_, err = client.Get(server.URL)
+	var rateLimitErr *RateLimitError
+	if !errors.As(err, &rateLimitErr) {
+		t.Fatalf("Expected RateLimitError, got %v", err)
+	}
+	if !rateLimitErr.Reset.Equal(reset) {
+		t.Errorf("Expected reset %v, got %v", reset, rateLimitErr.Reset)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("Expected the aborted request to not be sent, got %d requests", calls.Load())
+	}
+	if len(*slept) != 0 {
+		t.Errorf("Expected no waiting, got %v", *slept)
+	}
+}
@@ -0,0 +1,345 @@
+package core
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	ActionDone        = "done"
+	ActionRead        = "read"
+	ActionUnsubscribe = "unsubscribe"
+	ActionKeep        = "keep"
+)
+
+// Rule clears or keeps the notifications it matches. Rules are evaluated in
+// order and the first one that matches decides the action.
+type Rule struct {
+	Name   string    `mapstructure:"name" json:"name,omitempty"`
+	Match  RuleMatch `mapstructure:"match" json:"match"`
+	Action string    `mapstructure:"action" json:"action"`
+}
+
+// RuleMatch lists the conditions a notification must meet for a rule to apply.
+// Empty conditions match everything; a list matches if any entry matches.
+type RuleMatch struct {
+	Repository []string `mapstructure:"repository" json:"repository,omitempty"`
+	Owner      []string `mapstructure:"owner" json:"owner,omitempty"`
+	Type       []string `mapstructure:"type" json:"type,omitempty"`
+	Reason     []string `mapstructure:"reason" json:"reason,omitempty"`
+	Title      string   `mapstructure:"title" json:"title,omitempty"`
+	OlderThan  string   `mapstructure:"older_than" json:"older_than,omitempty"`
+	NewerThan  string   `mapstructure:"newer_than" json:"newer_than,omitempty"`
+	PRState    []string `mapstructure:"pr_state" json:"pr_state,omitempty"`
+	IssueState []string `mapstructure:"issue_state" json:"issue_state,omitempty"`
+	Author     []string `mapstructure:"author" json:"author,omitempty"`
+	// Reactivated matches threads that were cleared before and have had new
+	// activity since, or with false only those that have not.
+	Reactivated *bool `mapstructure:"reactivated" json:"reactivated,omitempty"`
+}
+
+// DefaultRuleOptions tunes the rules used when none are configured.
+type DefaultRuleOptions struct {
+	// Action is how matching threads are cleared, done or read. It defaults
+	// to done.
+	Action                string
+	ClearClosedPRs        bool
+	ClearNotPlannedIssues bool
+}
+
+// DefaultRules clears notifications for merged pull requests and for issues
+// closed as completed, and keeps everything else. Threads with new activity
+// after being cleared are kept, so that a discussion on a merged PR is seen.
+func DefaultRules(opts DefaultRuleOptions) []Rule {
+	action := opts.Action
+	if action == "" {
+		action = ActionDone
+	}
+
+	prStates := []string{PRStateMerged}
+	if opts.ClearClosedPRs {
+		prStates = append(prStates, PRStateClosed)
+	}
+
+	issueStates := []string{IssueStateCompleted}
+	if opts.ClearNotPlannedIssues {
+		issueStates = append(issueStates, IssueStateNotPlanned)
+	}
+
+	reactivated := true
+	return []Rule{
+		{
+			Name:   "reactivated threads",
+			Match:  RuleMatch{Reactivated: &reactivated},
+			Action: ActionKeep,
+		},
+		{
+			Name:   "finished pull requests",
+			Match:  RuleMatch{Type: []string{"PullRequest"}, PRState: prStates},
+			Action: action,
+		},
+		{
+			Name:   "closed issues",
+			Match:  RuleMatch{Type: []string{"Issue"}, IssueState: issueStates},
+			Action: action,
+		},
+	}
+}
+
+// SubjectLookup fetches the state of a notification's subject on demand.
+type SubjectLookup struct {
+	PRStatus    func() (PRStatus, error)
+	IssueStatus func() (IssueStatus, error)
+	// Reactivated reports whether the thread was cleared before and has had
+	// new activity since.
+	Reactivated func() bool
+}
+
+type RuleSet struct {
+	rules []compiledRule
+}
+
+type compiledRule struct {
+	Rule
+	title     *regexp.Regexp
+	olderThan time.Duration
+	newerThan time.Duration
+}
+
+// NewRuleSet validates rules and prepares them for evaluation.
+func NewRuleSet(rules []Rule) (*RuleSet, error) {
+	rs := &RuleSet{}
+	for i, rule := range rules {
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("rule %d", i+1)
+		}
+
+		compiled, err := compileRule(rule)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rule %q: %w", rule.Name, err)
+		}
+		rs.rules = append(rs.rules, compiled)
+	}
+	return rs, nil
+}
+
+func compileRule(rule Rule) (compiledRule, error) {
+	c := compiledRule{Rule: rule}
+
+	switch rule.Action {
+	case ActionDone, ActionRead, ActionUnsubscribe, ActionKeep:
+	default:
+		return c, fmt.Errorf("unknown action: %q", rule.Action)
+	}
+
+	for _, pattern := range rule.Match.Repository {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return c, fmt.Errorf("invalid repository pattern %q: %w", pattern, err)
+		}
+	}
+
+	for _, state := range rule.Match.PRState {
+		switch state {
+		case PRStateOpen, PRStateDraft, PRStateMerged, PRStateClosed:
+		default:
+			return c, fmt.Errorf("unknown pr_state: %q", state)
+		}
+	}
+
+	for _, state := range rule.Match.IssueState {
+		switch state {
+		case IssueStateOpen, IssueStateClosed, IssueStateCompleted, IssueStateNotPlanned, IssueStateDuplicate:
+		default:
+			return c, fmt.Errorf("unknown issue_state: %q", state)
+		}
+	}
+
+	var err error
+	if rule.Match.Title != "" {
+		if c.title, err = regexp.Compile(rule.Match.Title); err != nil {
+			return c, fmt.Errorf("invalid title pattern: %w", err)
+		}
+	}
+	if rule.Match.OlderThan != "" {
+		if c.olderThan, err = ParseDuration(rule.Match.OlderThan); err != nil {
+			return c, fmt.Errorf("invalid older_than: %w", err)
+		}
+	}
+	if rule.Match.NewerThan != "" {
+		if c.newerThan, err = ParseDuration(rule.Match.NewerThan); err != nil {
+			return c, fmt.Errorf("invalid newer_than: %w", err)
+		}
+	}
+	return c, nil
+}
+
+// NeedsPRStatus reports whether any rule has a condition that requires the
+// status of a pull request.
+func (rs *RuleSet) NeedsPRStatus() bool {
+	for _, rule := range rs.rules {
+		if len(rule.Match.PRState) > 0 || len(rule.Match.Author) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Evaluate returns the first rule matching the notification, or a keep rule if
+// none does. Subject states are only looked up when a rule has a condition on
+// them, so notifications decided by cheaper conditions cost no API calls.
+func (rs *RuleSet) Evaluate(n Notification, now time.Time, lookup SubjectLookup) (Rule, error) {
+	subject := &subjectState{lookup: lookup}
+	for _, rule := range rs.rules {
+		matched, err := rule.matches(n, now, subject)
+		if err != nil {
+			return Rule{}, err
+		}
+		if matched {
+			return rule.Rule, nil
+		}
+	}
+	return Rule{Name: "no matching rule", Action: ActionKeep}, nil
+}
+
+// subjectState memoizes lookups so each subject is fetched at most once per
+// evaluation.
+type subjectState struct {
+	lookup SubjectLookup
+	pr     *PRStatus
+	issue  *IssueStatus
+}
+
+func (s *subjectState) prStatus() (*PRStatus, error) {
+	if s.pr == nil {
+		status, err := s.lookup.PRStatus()
+		if err != nil {
+			return nil, err
+		}
+		s.pr = &status
+	}
+	return s.pr, nil
+}
+
+func (s *subjectState) reactivated() bool {
+	return s.lookup.Reactivated != nil && s.lookup.Reactivated()
+}
+
+func (s *subjectState) issueStatus() (*IssueStatus, error) {
+	if s.issue == nil {
+		status, err := s.lookup.IssueStatus()
+		if err != nil {
+			return nil, err
+		}
+		s.issue = &status
+	}
+	return s.issue, nil
+}
+
+func (r compiledRule) matches(n Notification, now time.Time, subject *subjectState) (bool, error) {
+	m := r.Match
+
+	if len(m.Repository) > 0 && !matchesGlob(m.Repository, n.Repository.FullName) {
+		return false, nil
+	}
+	if len(m.Owner) > 0 && !matchesFold(m.Owner, n.Repository.Owner.Login) {
+		return false, nil
+	}
+	if len(m.Type) > 0 && !matchesFold(m.Type, n.Subject.Type) {
+		return false, nil
+	}
+	if len(m.Reason) > 0 && !matchesFold(m.Reason, n.Reason) {
+		return false, nil
+	}
+	if r.title != nil && !r.title.MatchString(n.Subject.Title) {
+		return false, nil
+	}
+	age := now.Sub(n.UpdatedAt)
+	if r.olderThan > 0 && age < r.olderThan {
+		return false, nil
+	}
+	if r.newerThan > 0 && age > r.newerThan {
+		return false, nil
+	}
+	if m.Reactivated != nil && subject.reactivated() != *m.Reactivated {
+		return false, nil
+	}
+
+	if len(m.PRState) > 0 || (len(m.Author) > 0 && n.Subject.Type == "PullRequest") {
+		if n.Subject.Type != "PullRequest" {
+			return false, nil
+		}
+		status, err := subject.prStatus()
+		if err != nil {
+			return false, err
+		}
+		if len(m.PRState) > 0 && !matchesPRState(m.PRState, *status) {
+			return false, nil
+		}
+		if len(m.Author) > 0 && !matchesFold(m.Author, status.Author) {
+			return false, nil
+		}
+	}
+
+	if len(m.IssueState) > 0 || (len(m.Author) > 0 && n.Subject.Type == "Issue") {
+		if n.Subject.Type != "Issue" {
+			return false, nil
+		}
+		status, err := subject.issueStatus()
+		if err != nil {
+			return false, err
+		}
+		if len(m.IssueState) > 0 && !matchesIssueState(m.IssueState, *status) {
+			return false, nil
+		}
+		if len(m.Author) > 0 && !matchesFold(m.Author, status.Author) {
+			return false, nil
+		}
+	}
+
+	if len(m.Author) > 0 && n.Subject.Type != "PullRequest" && n.Subject.Type != "Issue" {
+		return false, nil
+	}
+	return true, nil
+}
+
+// matchesPRState treats drafts as open, so open matches every PR that has not
+// been merged or closed.
+func matchesPRState(states []string, status PRStatus) bool {
+	for _, state := range states {
+		if state == PRStateOpen && status.State == PRStateDraft {
+			return true
+		}
+	}
+	return matchesFold(states, status.State)
+}
+
+func matchesIssueState(states []string, status IssueStatus) bool {
+	for _, state := range states {
+		if state == IssueStateClosed && status.Closed {
+			return true
+		}
+	}
+	return matchesFold(states, status.State())
+}
+
+func matchesFold(candidates []string, value string) bool {
+	for _, c := range candidates {
+		if strings.EqualFold(c, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesGlob(patterns []string, value string) bool {
+	value = strings.ToLower(value)
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(strings.ToLower(pattern), value); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,293 @@
+package core
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestNewRuleSet_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		rule Rule
+	}{
+		{"unknown action", Rule{Action: "archive"}},
+		{"bad title", Rule{Action: ActionDone, Match: RuleMatch{Title: "("}}},
+		{"bad older_than", Rule{Action: ActionDone, Match: RuleMatch{OlderThan: "soon"}}},
+		{"bad newer_than", Rule{Action: ActionDone, Match: RuleMatch{NewerThan: "7x"}}},
+		{"bad repository", Rule{Action: ActionDone, Match: RuleMatch{Repository: []string{"org/["}}}},
+		{"bad pr_state", Rule{Action: ActionDone, Match: RuleMatch{PRState: []string{"abandoned"}}}},
+		{"bad issue_state", Rule{Action: ActionDone, Match: RuleMatch{IssueState: []string{"wontfix"}}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewRuleSet([]Rule{tt.rule}); err == nil {
+				t.Error("Expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestRuleSet_Evaluate(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	rules, err := NewRuleSet([]Rule{
+		{Name: "keep security", Match: RuleMatch{Title: "(?i)security"}, Action: ActionKeep},
+		{Name: "bots", Match: RuleMatch{Type: []string{"PullRequest"}, Author: []string{"dependabot[bot]"}}, Action: ActionUnsubscribe},
+		{Name: "ci", Match: RuleMatch{Reason: []string{"ci_activity"}}, Action: ActionDone},
+		{Name: "stale org", Match: RuleMatch{Repository: []string{"myorg/*"}, OlderThan: "14d"}, Action: ActionRead},
+		{Name: "merged", Match: RuleMatch{PRState: []string{PRStateMerged}}, Action: ActionDone},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	pr := func(title, repo string, age time.Duration) Notification {
+		return Notification{
+			UpdatedAt:  now.Add(-age),
+			Subject:    Subject{Type: "PullRequest", Title: title},
+			Repository: Repository{FullName: repo},
+		}
+	}
+
+	tests := []struct {
+		name         string
+		notification Notification
+		status       PRStatus
+		wantRule     string
+		wantAction   string
+		wantLookup   bool
+	}{
+		{
+			name:         "first match wins",
+			notification: pr("Fix security hole", "myorg/app", time.Hour),
+			status:       PRStatus{State: PRStateMerged},
+			wantRule:     "keep security",
+			wantAction:   ActionKeep,
+		},
+		{
+			name:         "author condition",
+			notification: pr("Bump deps", "other/app", time.Hour),
+			status:       PRStatus{Author: "dependabot[bot]"},
+			wantRule:     "bots",
+			wantAction:   ActionUnsubscribe,
+			wantLookup:   true,
+		},
+		{
+			name:         "reason without lookup",
+			notification: Notification{Reason: "ci_activity", Subject: Subject{Type: "CheckSuite"}},
+			wantRule:     "ci",
+			wantAction:   ActionDone,
+		},
+		{
+			name:         "repository glob and age",
+			notification: pr("Refactor", "MyOrg/app", 30*24*time.Hour),
+			status:       PRStatus{},
+			wantRule:     "stale org",
+			wantAction:   ActionRead,
+			wantLookup:   true,
+		},
+		{
+			name:         "too recent for age rule",
+			notification: pr("Refactor", "myorg/app", time.Hour),
+			status:       PRStatus{State: PRStateMerged},
+			wantRule:     "merged",
+			wantAction:   ActionDone,
+			wantLookup:   true,
+		},
+		{
+			name:         "no match keeps",
+			notification: pr("Refactor", "other/app", time.Hour),
+			status:       PRStatus{},
+			wantAction:   ActionKeep,
+			wantLookup:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lookups := 0
+			rule, err := rules.Evaluate(tt.notification, now, SubjectLookup{
+				PRStatus: func() (PRStatus, error) {
+					lookups++
+					return tt.status, nil
+				},
+			})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if rule.Action != tt.wantAction {
+				t.Errorf("Expected action %q, got %q", tt.wantAction, rule.Action)
+			}
+			if tt.wantRule != "" && rule.Name != tt.wantRule {
+				t.Errorf("Expected rule %q, got %q", tt.wantRule, rule.Name)
+			}
+			if tt.wantLookup && lookups != 1 {
+				t.Errorf("Expected exactly 1 PR lookup, got %d", lookups)
+			}
+			if !tt.wantLookup && lookups != 0 {
+				t.Errorf("Expected no PR lookup, got %d", lookups)
+			}
+		})
+	}
+}
+
+func TestRuleSet_Evaluate_LookupError(t *testing.T) {
+	rules, err := NewRuleSet(DefaultRules(DefaultRuleOptions{}))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	n := Notification{Subject: Subject{Type: "PullRequest"}}
+	_, err = rules.Evaluate(n, time.Now(), SubjectLookup{
+		PRStatus: func() (PRStatus, error) {
+			return PRStatus{}, errors.New("API error")
+		},
+	})
+	if err == nil {
+		t.Error("Expected lookup error to be returned, got nil")
+	}
+}
+
+func TestRuleSet_DefaultRulesIgnoreOtherSubjects(t *testing.T) {
+	rules, err := NewRuleSet(DefaultRules(DefaultRuleOptions{}))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	n := Notification{Subject: Subject{Type: "Release"}}
+	rule, err := rules.Evaluate(n, time.Now(), SubjectLookup{
+		PRStatus: func() (PRStatus, error) {
+			t.Error("Unexpected PR lookup for a release")
+			return PRStatus{}, nil
+		},
+		IssueStatus: func() (IssueStatus, error) {
+			t.Error("Unexpected issue lookup for a release")
+			return IssueStatus{}, nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if rule.Action != ActionKeep {
+		t.Errorf("Expected release to be kept, got %q", rule.Action)
+	}
+}
+
+func TestRuleSet_IssueState(t *testing.T) {
+	rules, err := NewRuleSet([]Rule{
+		{Name: "closed", Match: RuleMatch{IssueState: []string{IssueStateClosed}}, Action: ActionDone},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		status IssueStatus
+		want   string
+	}{
+		{IssueStatus{}, ActionKeep},
+		{IssueStatus{Closed: true, StateReason: "completed"}, ActionDone},
+		{IssueStatus{Closed: true, StateReason: "not_planned"}, ActionDone},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.status.State(), func(t *testing.T) {
+			n := Notification{Subject: Subject{Type: "Issue"}}
+			rule, err := rules.Evaluate(n, time.Now(), SubjectLookup{
+				IssueStatus: func() (IssueStatus, error) { return tt.status, nil },
+			})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if rule.Action != tt.want {
+				t.Errorf("Expected action %q, got %q", tt.want, rule.Action)
+			}
+		})
+	}
+}
+
+func TestRuleSet_PRState(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   DefaultRuleOptions
+		status PRStatus
+		want   string
+	}{
+		{"merged", DefaultRuleOptions{}, PRStatus{State: PRStateMerged}, ActionDone},
+		{"closed kept by default", DefaultRuleOptions{}, PRStatus{State: PRStateClosed}, ActionKeep},
+		{"closed cleared when enabled", DefaultRuleOptions{ClearClosedPRs: true}, PRStatus{State: PRStateClosed}, ActionDone},
+		{"draft kept", DefaultRuleOptions{ClearClosedPRs: true}, PRStatus{State: PRStateDraft}, ActionKeep},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules, err := NewRuleSet(DefaultRules(tt.opts))
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			n := Notification{Subject: Subject{Type: "PullRequest"}}
+			rule, err := rules.Evaluate(n, time.Now(), SubjectLookup{
+				PRStatus: func() (PRStatus, error) { return tt.status, nil },
+			})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if rule.Action != tt.want {
+				t.Errorf("Expected action %q, got %q", tt.want, rule.Action)
+			}
+		})
+	}
+}
+
+func TestMatchesPRState_DraftIsOpen(t *testing.T) {
+	draft := PRStatus{State: PRStateDraft}
+	if !matchesPRState([]string{PRStateOpen}, draft) {
+		t.Error("Expected open to match a draft PR")
+	}
+	if matchesPRState([]string{PRStateDraft}, PRStatus{State: PRStateOpen}) {
+		t.Error("Expected draft to not match a ready PR")
+	}
+}
+
+func TestRuleSet_Reactivated(t *testing.T) {
+	rules, err := NewRuleSet(DefaultRules(DefaultRuleOptions{}))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	merged := func() (PRStatus, error) { return PRStatus{State: PRStateMerged}, nil }
+	n := Notification{Subject: Subject{Type: "PullRequest"}}
+
+	for _, reactivated := range []bool{true, false} {
+		rule, err := rules.Evaluate(n, time.Now(), SubjectLookup{
+			PRStatus:    merged,
+			Reactivated: func() bool { return reactivated },
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		want := ActionDone
+		if reactivated {
+			want = ActionKeep
+		}
+		if rule.Action != want {
+			t.Errorf("Reactivated %v: expected %q, got %q from rule %q", reactivated, want, rule.Action, rule.Name)
+		}
+	}
+}
+
+func TestDefaultRules_Action(t *testing.T) {
+	for _, rule := range DefaultRules(DefaultRuleOptions{}) {
+		if rule.Action != ActionDone && rule.Action != ActionKeep {
+			t.Errorf("Expected rule %q to default to %q, got %q", rule.Name, ActionDone, rule.Action)
+		}
+	}
+	for _, rule := range DefaultRules(DefaultRuleOptions{Action: ActionRead}) {
+		if rule.Action != ActionRead && rule.Action != ActionKeep {
+			t.Errorf("Expected rule %q to use %q, got %q", rule.Name, ActionRead, rule.Action)
+		}
+	}
+}
@@ -0,0 +1,1007 @@
+package core
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// sqliteMigrations[i] takes the database from user_version i to i+1.
+var sqliteMigrations = []string{
+	`CREATE TABLE meta (
+		key   TEXT PRIMARY KEY,
+		value INTEGER NOT NULL
+	);
+	CREATE TABLE pr_status (
+		url        TEXT PRIMARY KEY,
+		state      TEXT NOT NULL,
+		author     TEXT NOT NULL,
+		merged_at  INTEGER,
+		closed_at  INTEGER,
+		fetched_at INTEGER NOT NULL,
+		used_at    INTEGER NOT NULL
+	);
+	CREATE INDEX pr_status_used_at ON pr_status (used_at);
+	CREATE TABLE issue_status (
+		url          TEXT PRIMARY KEY,
+		closed       INTEGER NOT NULL,
+		state_reason TEXT NOT NULL,
+		author       TEXT NOT NULL,
+		fetched_at   INTEGER NOT NULL,
+		used_at      INTEGER NOT NULL
+	);
+	CREATE INDEX issue_status_used_at ON issue_status (used_at);
+	CREATE TABLE threads_deleted (
+		id         TEXT PRIMARY KEY,
+		updated_at INTEGER NOT NULL,
+		used_at    INTEGER NOT NULL
+	);
+	CREATE INDEX threads_deleted_used_at ON threads_deleted (used_at);
+	CREATE TABLE threads_unsubscribed (
+		id TEXT PRIMARY KEY
+	);
+	CREATE TABLE responses (
+		key           TEXT PRIMARY KEY,
+		etag          TEXT NOT NULL,
+		last_modified TEXT NOT NULL,
+		link          TEXT NOT NULL,
+		body          BLOB NOT NULL,
+		used_at       INTEGER NOT NULL
+	);
+	CREATE INDEX responses_used_at ON responses (used_at);
+	CREATE TABLE lookups (
+		kind   TEXT PRIMARY KEY,
+		hits   INTEGER NOT NULL,
+		misses INTEGER NOT NULL
+	);
+	CREATE TABLE runs (
+		id          INTEGER PRIMARY KEY AUTOINCREMENT,
+		started_at  INTEGER NOT NULL,
+		finished_at INTEGER NOT NULL,
+		since       TEXT NOT NULL,
+		fetched     INTEGER NOT NULL,
+		filtered    INTEGER NOT NULL,
+		skipped     INTEGER NOT NULL,
+		checked     INTEGER NOT NULL,
+		cleared     INTEGER NOT NULL,
+		failed      INTEGER NOT NULL
+	);
+	CREATE INDEX runs_started_at ON runs (started_at);
+	CREATE TABLE run_repositories (
+		run_id     INTEGER NOT NULL REFERENCES runs (id) ON DELETE CASCADE,
+		repository TEXT NOT NULL,
+		fetched    INTEGER NOT NULL,
+		filtered   INTEGER NOT NULL,
+		skipped    INTEGER NOT NULL,
+		checked    INTEGER NOT NULL,
+		cleared    INTEGER NOT NULL,
+		failed     INTEGER NOT NULL,
+		PRIMARY KEY (run_id, repository)
+	);
+	CREATE INDEX run_repositories_repository ON run_repositories (repository);`,
+	`ALTER TABLE runs ADD COLUMN error TEXT NOT NULL DEFAULT '';`,
+}
+
+// sqliteTables maps each kind of entry to its table and key column.
+var sqliteTables = map[string]struct {
+	table, key string
+	// usedAt is set for tables with a used_at column.
+	usedAt bool
+}{
+	CacheKindPR:           {"pr_status", "url", true},
+	CacheKindIssue:        {"issue_status", "url", true},
+	CacheKindThread:       {"threads_deleted", "id", true},
+	CacheKindUnsubscribed: {"threads_unsubscribed", "id", false},
+	CacheKindResponse:     {"responses", "key", true},
+}
+
+const (
+	metaLastSweep = "last_sweep"
+	metaMaxWindow = "max_window"
+)
+
+// sqliteCacheService keeps the cache in an SQLite database, so that runs
+// read and write single entries instead of the whole cache. Lookups and the
+// used_at of entries that were read are written on Save. It also keeps the
+// run history.
+type sqliteCacheService struct {
+	cacheOptions
+	dir     string
+	dbFile  string
+	now     func() time.Time
+	db      *sql.DB
+	version int
+
+	mu sync.Mutex
+	// touched holds when entries of each kind were read since the last Save.
+	touched map[string]map[string]time.Time
+	lookups map[string]Lookups
+}
+
+// NewSQLiteCacheService returns a cache kept in ~/.dailyare/cache.db. When
+// the database is created, the entries of an existing cache.json are
+// imported into it.
+func NewSQLiteCacheService(homeDir string, opts ...CacheOption) CacheService {
+	return &sqliteCacheService{
+		cacheOptions: newCacheOptions(opts),
+		dir:          CacheDir(homeDir),
+		dbFile:       "cache.db",
+		now:          time.Now,
+		touched:      make(map[string]map[string]time.Time),
+		lookups:      make(map[string]Lookups),
+	}
+}
+
+func (s *sqliteCacheService) path() string {
+	return filepath.Join(s.dir, s.dbFile)
+}
+
+func (s *sqliteCacheService) Load() (*Cache, error) {
+	if s.db == nil {
+		if err := s.open(); err != nil {
+			return nil, err
+		}
+	}
+
+	cache := &Cache{Version: cacheVersion}
+	var err error
+	if cache.LastSweep, err = s.metaTime(metaLastSweep); err != nil {
+		return nil, err
+	}
+	window, err := s.metaInt(metaMaxWindow)
+	if err != nil {
+		return nil, err
+	}
+	cache.MaxWindow = time.Duration(window)
+	return cache, nil
+}
+
+func (s *sqliteCacheService) open() error {
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return err
+	}
+	db, err := sql.Open("sqlite", s.path()+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=synchronous(NORMAL)&_pragma=foreign_keys(1)")
+	if err != nil {
+		return err
+	}
+	// A single connection serializes writers, which the run lock already
+	// does between processes.
+	db.SetMaxOpenConns(1)
+
+	version, err := migrateSQLite(db)
+	if err != nil {
+		db.Close()
+		return fmt.Errorf("%s: %w", s.path(), err)
+	}
+	s.db = db
+	s.version = len(sqliteMigrations)
+
+	if version == 0 {
+		s.importFileCache()
+	}
+	return nil
+}
+
+// migrateSQLite brings the schema up to date and returns the version the
+// database had before.
+func migrateSQLite(db *sql.DB) (int, error) {
+	var version int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return 0, err
+	}
+	if version > len(sqliteMigrations) {
+		return version, fmt.Errorf("%w: schema version %d, this dailyare reads up to %d", ErrCacheTooNew, version, len(sqliteMigrations))
+	}
+
+	for v := version; v < len(sqliteMigrations); v++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return version, err
+		}
+		if _, err := tx.Exec(sqliteMigrations[v]); err != nil {
+			tx.Rollback()
+			return version, fmt.Errorf("failed to migrate cache database from version %d: %w", v, err)
+		}
+		// PRAGMA does not take parameters.
+		if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", v+1)); err != nil {
+			tx.Rollback()
+			return version, err
+		}
+		if err := tx.Commit(); err != nil {
+			return version, err
+		}
+	}
+	return version, nil
+}
+
+// importFileCache copies the JSON cache into a new database, so that
+// switching backends does not start from an empty cache. The JSON file is
+// left in place.
+func (s *sqliteCacheService) importFileCache() {
+	path := filepath.Join(s.dir, "cache.json")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	cache, _, err := decodeCache(data)
+	if err != nil {
+		s.logger.Info("Not importing the JSON cache", "path", path, "error", err.Error())
+		return
+	}
+	ensureMaps(cache)
+	touchUnusedEntries(cache, s.now())
+	if err := s.replace(cache); err != nil {
+		s.logger.Error(err, "Failed to import the JSON cache", "path", path)
+		return
+	}
+	s.logger.Info("Imported the JSON cache", "path", path)
+}
+
+// Save writes the lookups and the used_at of entries read since the last
+// Save, then applies the eviction policy. The cache itself is written as it
+// changes.
+func (s *sqliteCacheService) Save(*Cache) error {
+	evicted, err := s.flush(true)
+	if err != nil {
+		return err
+	}
+	logEvicted(s.logger, evicted)
+	return nil
+}
+
+// flush writes the pending lookups and used_at timestamps, evicting entries
+// afterwards if evict is set.
+func (s *sqliteCacheService) flush(evict bool) (map[string]int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	for kind, keys := range s.touched {
+		t := sqliteTables[kind]
+		query := fmt.Sprintf("UPDATE %s SET used_at = max(used_at, ?) WHERE %s = ?", t.table, t.key)
+		for key, usedAt := range keys {
+			if _, err := tx.Exec(query, usedAt.UnixNano(), key); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for kind, lookups := range s.lookups {
+		if _, err := tx.Exec(`INSERT INTO lookups (kind, hits, misses) VALUES (?, ?, ?)
+			ON CONFLICT (kind) DO UPDATE SET hits = hits + excluded.hits, misses = misses + excluded.misses`,
+			kind, lookups.Hits, lookups.Misses); err != nil {
+			return nil, err
+		}
+	}
+
+	var evicted map[string]int
+	if evict {
+		if evicted, err = s.evict(tx); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	clear(s.touched)
+	clear(s.lookups)
+	return evicted, nil
+}
+
+func (s *sqliteCacheService) evict(tx *sql.Tx) (map[string]int, error) {
+	var window int64
+	if err := tx.QueryRow("SELECT value FROM meta WHERE key = ?", metaMaxWindow).Scan(&window); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	now := s.now()
+
+	evicted := make(map[string]int)
+	for _, kind := range CacheKinds {
+		t := sqliteTables[kind]
+		if !t.usedAt {
+			continue
+		}
+		if cutoff := s.eviction.cutoff(now, time.Duration(window), kind); !cutoff.IsZero() {
+			n, err := execCount(tx, fmt.Sprintf("DELETE FROM %s WHERE used_at < ?", t.table), cutoff.UnixNano())
+			if err != nil {
+				return nil, err
+			}
+			evicted[kind] += n
+		}
+		if s.eviction.MaxEntries > 0 {
+			n, err := execCount(tx, fmt.Sprintf(
+				"DELETE FROM %[1]s WHERE %[2]s NOT IN (SELECT %[2]s FROM %[1]s ORDER BY used_at DESC, %[2]s LIMIT ?)",
+				t.table, t.key), s.eviction.MaxEntries)
+			if err != nil {
+				return nil, err
+			}
+			evicted[kind] += n
+		}
+	}
+
+	n, err := execCount(tx, "DELETE FROM threads_unsubscribed WHERE id NOT IN (SELECT id FROM threads_deleted)")
+	if err != nil {
+		return nil, err
+	}
+	evicted[CacheKindUnsubscribed] = n
+	return evicted, nil
+}
+
+// sqlExecer is satisfied by both *sql.DB and *sql.Tx.
+type sqlExecer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+func execCount(db sqlExecer, query string, args ...any) (int, error) {
+	result, err := db.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	return int(n), err
+}
+
+// recordLookup counts a lookup, and on a hit records that the entry at key
+// was used.
+func (s *sqliteCacheService) recordLookup(kind, key string, hit bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	lookups := s.lookups[kind]
+	if hit {
+		lookups.Hits++
+		s.recordUse(kind, key)
+	} else {
+		lookups.Misses++
+	}
+	s.lookups[kind] = lookups
+}
+
+// recordUse notes that the entry at key was read, to be written by Save.
+// s.mu must be held.
+func (s *sqliteCacheService) recordUse(kind, key string) {
+	if !sqliteTables[kind].usedAt {
+		return
+	}
+	if s.touched[kind] == nil {
+		s.touched[kind] = make(map[string]time.Time)
+	}
+	s.touched[kind][key] = s.now()
+}
+
+// logFailure reports a failed read or write. The cache is best effort, so
+// the run carries on as if the entry were not cached.
+func (s *sqliteCacheService) logFailure(err error, msg, key string) {
+	s.logger.Error(err, msg, "key", key)
+}
+
+func (s *sqliteCacheService) IsThreadDeleted(id string, updatedAt time.Time) bool {
+	var clearedAt int64
+	err := s.db.QueryRow("SELECT updated_at FROM threads_deleted WHERE id = ?", id).Scan(&clearedAt)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		s.logFailure(err, "Failed to read cleared thread", id)
+	}
+	if err == nil && clearedAt == 0 {
+		// Threads imported from a cache that did not record their
+		// updated_at adopt the one they are next fetched with.
+		clearedAt = unixNano(updatedAt)
+		s.SetThreadDeleted(id, updatedAt)
+	}
+	if err == nil {
+		s.mu.Lock()
+		s.recordUse(CacheKindThread, id)
+		s.mu.Unlock()
+	}
+	deleted := err == nil && !updatedAt.After(fromUnixNano(clearedAt))
+	s.recordLookup(CacheKindThread, id, deleted)
+	return deleted
+}
+
+func (s *sqliteCacheService) IsThreadReactivated(id string, updatedAt time.Time) bool {
+	var clearedAt int64
+	err := s.db.QueryRow("SELECT updated_at FROM threads_deleted WHERE id = ?", id).Scan(&clearedAt)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		s.logFailure(err, "Failed to read cleared thread", id)
+	}
+	return err == nil && clearedAt != 0 && updatedAt.After(fromUnixNano(clearedAt))
+}
+
+func (s *sqliteCacheService) SetThreadDeleted(id string, updatedAt time.Time) {
+	if err := putClearedThread(s.db, id, ClearedThread{UpdatedAt: updatedAt, UsedAt: s.now()}); err != nil {
+		s.logFailure(err, "Failed to write cleared thread", id)
+	}
+}
+
+func (s *sqliteCacheService) IsThreadUnsubscribed(id string) bool {
+	var found int
+	err := s.db.QueryRow("SELECT 1 FROM threads_unsubscribed WHERE id = ?", id).Scan(&found)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		s.logFailure(err, "Failed to read unsubscribed thread", id)
+	}
+	s.recordLookup(CacheKindUnsubscribed, id, err == nil)
+	return err == nil
+}
+
+func (s *sqliteCacheService) SetThreadUnsubscribed(id string) {
+	if err := putUnsubscribed(s.db, id); err != nil {
+		s.logFailure(err, "Failed to write unsubscribed thread", id)
+	}
+}
+
+func (s *sqliteCacheService) GetPRStatus(url string) (PRStatus, bool) {
+	_, status, err := scanPRStatus(s.db.QueryRow(selectPRStatus+" WHERE url = ?", url))
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		s.logFailure(err, "Failed to read PR status", url)
+	}
+	s.recordLookup(CacheKindPR, url, err == nil)
+	return status, err == nil
+}
+
+func (s *sqliteCacheService) SetPRStatus(url string, status PRStatus) {
+	status.UsedAt = s.now()
+	if err := putPRStatus(s.db, url, status); err != nil {
+		s.logFailure(err, "Failed to write PR status", url)
+	}
+}
+
+func (s *sqliteCacheService) GetIssueStatus(url string) (IssueStatus, bool) {
+	_, status, err := scanIssueStatus(s.db.QueryRow(selectIssueStatus+" WHERE url = ?", url))
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		s.logFailure(err, "Failed to read issue status", url)
+	}
+	s.recordLookup(CacheKindIssue, url, err == nil)
+	return status, err == nil
+}
+
+func (s *sqliteCacheService) SetIssueStatus(url string, status IssueStatus) {
+	status.UsedAt = s.now()
+	if err := putIssueStatus(s.db, url, status); err != nil {
+		s.logFailure(err, "Failed to write issue status", url)
+	}
+}
+
+func (s *sqliteCacheService) GetResponse(key string) (CachedResponse, bool) {
+	_, response, err := scanResponse(s.db.QueryRow(selectResponse+" WHERE key = ?", key))
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		s.logFailure(err, "Failed to read cached response", key)
+	}
+	s.recordLookup(CacheKindResponse, key, err == nil)
+	return response, err == nil
+}
+
+func (s *sqliteCacheService) SetResponse(key string, response CachedResponse) {
+	response.UsedAt = s.now()
+	if err := putResponse(s.db, key, response); err != nil {
+		s.logFailure(err, "Failed to write cached response", key)
+	}
+}
+
+func (s *sqliteCacheService) GetLastSweep() time.Time {
+	t, err := s.metaTime(metaLastSweep)
+	if err != nil {
+		s.logFailure(err, "Failed to read cache metadata", metaLastSweep)
+	}
+	return t
+}
+
+// SetLastSweep never moves the watermark backwards.
+func (s *sqliteCacheService) SetLastSweep(t time.Time) {
+	if err := putMetaMax(s.db, metaLastSweep, t.UnixNano()); err != nil {
+		s.logFailure(err, "Failed to write cache metadata", metaLastSweep)
+	}
+}
+
+func (s *sqliteCacheService) GetMaxWindow() time.Duration {
+	window, err := s.metaInt(metaMaxWindow)
+	if err != nil {
+		s.logFailure(err, "Failed to read cache metadata", metaMaxWindow)
+	}
+	return time.Duration(window)
+}
+
+// SetMaxWindow records the window of a sweep, keeping the longest.
+func (s *sqliteCacheService) SetMaxWindow(window time.Duration) {
+	if err := putMetaMax(s.db, metaMaxWindow, int64(window)); err != nil {
+		s.logFailure(err, "Failed to write cache metadata", metaMaxWindow)
+	}
+}
+
+func (s *sqliteCacheService) metaInt(key string) (int64, error) {
+	var value int64
+	err := s.db.QueryRow("SELECT value FROM meta WHERE key = ?", key).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	return value, err
+}
+
+func (s *sqliteCacheService) metaTime(key string) (time.Time, error) {
+	value, err := s.metaInt(key)
+	return fromUnixNano(value), err
+}
+
+// putMetaMax stores value unless a larger one is stored already.
+func putMetaMax(db sqlExecer, key string, value int64) error {
+	_, err := db.Exec(`INSERT INTO meta (key, value) VALUES (?, ?)
+		ON CONFLICT (key) DO UPDATE SET value = max(value, excluded.value)`, key, value)
+	return err
+}
+
+func (s *sqliteCacheService) Stats() CacheStats {
+	stats := CacheStats{
+		Location:  s.path(),
+		Version:   s.version,
+		LastSweep: s.GetLastSweep(),
+		MaxWindow: s.GetMaxWindow(),
+		Kinds:     make(map[string]KindStats),
+	}
+
+	stored := make(map[string]Lookups)
+	rows, err := s.db.Query("SELECT kind, hits, misses FROM lookups")
+	if err != nil {
+		s.logger.Error(err, "Failed to read cache lookups")
+	} else {
+		for rows.Next() {
+			var kind string
+			var l Lookups
+			if err := rows.Scan(&kind, &l.Hits, &l.Misses); err == nil {
+				stored[kind] = l
+			}
+		}
+		rows.Close()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, kind := range CacheKinds {
+		var entries int
+		if err := s.db.QueryRow("SELECT count(*) FROM " + sqliteTables[kind].table).Scan(&entries); err != nil {
+			s.logger.Error(err, "Failed to count cache entries", "kind", kind)
+		}
+		lookups := stored[kind]
+		lookups.Hits += s.lookups[kind].Hits
+		lookups.Misses += s.lookups[kind].Misses
+		stats.Kinds[kind] = newKindStats(entries, lookups)
+	}
+	return stats
+}
+
+func (s *sqliteCacheService) Entries(kind string) ([]CacheEntry, error) {
+	kinds, err := selectKinds(kind)
+	if err != nil {
+		return nil, err
+	}
+	cache, err := s.snapshot()
+	if err != nil {
+		return nil, err
+	}
+
+	return cacheEntries(cache, kinds)
+}
+
+func (s *sqliteCacheService) Forget(kind, key string) (int, error) {
+	kinds, err := selectKinds(kind)
+	if err != nil {
+		return 0, err
+	}
+
+	var total int
+	for _, kind := range kinds {
+		t := sqliteTables[kind]
+		n, err := execCount(s.db, fmt.Sprintf("DELETE FROM %s WHERE %s = ?", t.table, t.key), key)
+		if err != nil {
+			return total, err
+		}
+		total += n
+	}
+	return total, nil
+}
+
+func (s *sqliteCacheService) Prune() (map[string]int, error) {
+	return s.flush(true)
+}
+
+// Clear removes every entry but keeps the run history.
+func (s *sqliteCacheService) Clear() error {
+	return s.replace(newCache())
+}
+
+// Close closes the database, which is opened again by the next Load.
+func (s *sqliteCacheService) Close() error {
+	if s.db == nil {
+		return nil
+	}
+	err := s.db.Close()
+	s.db = nil
+	return err
+}
+
+func (s *sqliteCacheService) Export(w io.Writer) error {
+	cache, err := s.snapshot()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(cache, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+func (s *sqliteCacheService) Import(r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	cache, _, err := decodeCache(data)
+	if err != nil {
+		return fmt.Errorf("invalid cache: %w", err)
+	}
+	ensureMaps(cache)
+	touchUnusedEntries(cache, s.now())
+	return s.replace(cache)
+}
+
+// replace swaps every entry, the metadata and the lookups for those of
+// cache, in one transaction.
+func (s *sqliteCacheService) replace(cache *Cache) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, table := range []string{"pr_status", "issue_status", "threads_deleted", "threads_unsubscribed", "responses", "lookups", "meta"} {
+		if _, err := tx.Exec("DELETE FROM " + table); err != nil {
+			return err
+		}
+	}
+	for url, status := range cache.PRStatus {
+		if err := putPRStatus(tx, url, status); err != nil {
+			return err
+		}
+	}
+	for url, status := range cache.IssueStatus {
+		if err := putIssueStatus(tx, url, status); err != nil {
+			return err
+		}
+	}
+	for id, thread := range cache.ThreadsDeleted {
+		if err := putClearedThread(tx, id, thread); err != nil {
+			return err
+		}
+	}
+	for id, unsubscribed := range cache.ThreadsUnsubscribed {
+		if !unsubscribed {
+			continue
+		}
+		if err := putUnsubscribed(tx, id); err != nil {
+			return err
+		}
+	}
+	for key, response := range cache.Responses {
+		if err := putResponse(tx, key, response); err != nil {
+			return err
+		}
+	}
+	for kind, lookups := range cache.Lookups {
+		if _, err := tx.Exec("INSERT INTO lookups (kind, hits, misses) VALUES (?, ?, ?)", kind, lookups.Hits, lookups.Misses); err != nil {
+			return err
+		}
+	}
+	if !cache.LastSweep.IsZero() {
+		if err := putMetaMax(tx, metaLastSweep, cache.LastSweep.UnixNano()); err != nil {
+			return err
+		}
+	}
+	if cache.MaxWindow > 0 {
+		if err := putMetaMax(tx, metaMaxWindow, int64(cache.MaxWindow)); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	clear(s.touched)
+	clear(s.lookups)
+	return nil
+}
+
+// snapshot reads the whole cache, for the commands that list or export it.
+func (s *sqliteCacheService) snapshot() (*Cache, error) {
+	cache := newCache()
+	var err error
+	if cache.LastSweep, err = s.metaTime(metaLastSweep); err != nil {
+		return nil, err
+	}
+	window, err := s.metaInt(metaMaxWindow)
+	if err != nil {
+		return nil, err
+	}
+	cache.MaxWindow = time.Duration(window)
+
+	err = queryRows(s.db, selectPRStatus, func(rows *sql.Rows) error {
+		url, status, err := scanPRStatus(rows)
+		cache.PRStatus[url] = status
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = queryRows(s.db, selectIssueStatus, func(rows *sql.Rows) error {
+		url, status, err := scanIssueStatus(rows)
+		cache.IssueStatus[url] = status
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = queryRows(s.db, "SELECT id, updated_at, used_at FROM threads_deleted", func(rows *sql.Rows) error {
+		var id string
+		var updatedAt, usedAt int64
+		err := rows.Scan(&id, &updatedAt, &usedAt)
+		cache.ThreadsDeleted[id] = ClearedThread{UpdatedAt: fromUnixNano(updatedAt), UsedAt: fromUnixNano(usedAt)}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = queryRows(s.db, "SELECT id FROM threads_unsubscribed", func(rows *sql.Rows) error {
+		var id string
+		err := rows.Scan(&id)
+		cache.ThreadsUnsubscribed[id] = true
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = queryRows(s.db, selectResponse, func(rows *sql.Rows) error {
+		key, response, err := scanResponse(rows)
+		cache.Responses[key] = response
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = queryRows(s.db, "SELECT kind, hits, misses FROM lookups", func(rows *sql.Rows) error {
+		var kind string
+		var lookups Lookups
+		err := rows.Scan(&kind, &lookups.Hits, &lookups.Misses)
+		cache.Lookups[kind] = lookups
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return cache, nil
+}
+
+// queryRows calls fn for each row of the query. The rows are closed before
+// it returns, so fn must not query the database itself.
+func queryRows(db *sql.DB, query string, fn func(*sql.Rows) error, args ...any) error {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err := fn(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (s *sqliteCacheService) RecordRun(run RunRecord) error {
+	// A run that failed to load the cache has nowhere to record itself.
+	if s.db == nil {
+		return errors.New("the cache database is not open")
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	c := run.Summary.Counts
+	result, err := tx.Exec(`INSERT INTO runs (started_at, finished_at, since, fetched, filtered, skipped, checked, cleared, failed, error)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		unixNano(run.StartedAt), unixNano(run.FinishedAt), run.Since,
+		c.Fetched, c.Filtered, c.Skipped, c.Checked, c.Cleared, c.Failed, run.Error)
+	if err != nil {
+		return err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	for repo, c := range run.Summary.Repositories {
+		if _, err := tx.Exec(`INSERT INTO run_repositories (run_id, repository, fetched, filtered, skipped, checked, cleared, failed)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			id, repo, c.Fetched, c.Filtered, c.Skipped, c.Checked, c.Cleared, c.Failed); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *sqliteCacheService) Runs(limit int) ([]RunRecord, error) {
+	var ids []int64
+	var runs []RunRecord
+	err := queryRows(s.db, `SELECT id, started_at, finished_at, since, fetched, filtered, skipped, checked, cleared, failed, error
+		FROM runs ORDER BY started_at DESC, id DESC LIMIT ?`, func(rows *sql.Rows) error {
+		var id, startedAt, finishedAt int64
+		var run RunRecord
+		c := &run.Summary.Counts
+		if err := rows.Scan(&id, &startedAt, &finishedAt, &run.Since,
+			&c.Fetched, &c.Filtered, &c.Skipped, &c.Checked, &c.Cleared, &c.Failed, &run.Error); err != nil {
+			return err
+		}
+		run.StartedAt, run.FinishedAt = fromUnixNano(startedAt), fromUnixNano(finishedAt)
+		ids = append(ids, id)
+		runs = append(runs, run)
+		return nil
+	}, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, id := range ids {
+		err := queryRows(s.db, `SELECT repository, fetched, filtered, skipped, checked, cleared, failed
+			FROM run_repositories WHERE run_id = ?`, func(rows *sql.Rows) error {
+			var repo string
+			var c Counts
+			if err := rows.Scan(&repo, &c.Fetched, &c.Filtered, &c.Skipped, &c.Checked, &c.Cleared, &c.Failed); err != nil {
+				return err
+			}
+			if runs[i].Summary.Repositories == nil {
+				runs[i].Summary.Repositories = make(map[string]Counts)
+			}
+			runs[i].Summary.Repositories[repo] = c
+			return nil
+		}, id)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return runs, nil
+}
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+const (
+	selectPRStatus    = "SELECT url, state, author, merged_at, closed_at, fetched_at, used_at FROM pr_status"
+	selectIssueStatus = "SELECT url, closed, state_reason, author, fetched_at, used_at FROM issue_status"
+	selectResponse    = "SELECT key, etag, last_modified, link, body, used_at FROM responses"
+)
+
+// scanPRStatus scans a row of selectPRStatus.
+func scanPRStatus(row rowScanner) (string, PRStatus, error) {
+	var url string
+	var status PRStatus
+	var mergedAt, closedAt sql.NullInt64
+	var fetchedAt, usedAt int64
+	if err := row.Scan(&url, &status.State, &status.Author, &mergedAt, &closedAt, &fetchedAt, &usedAt); err != nil {
+		return "", PRStatus{}, err
+	}
+	status.MergedAt, status.ClosedAt = nullTime(mergedAt), nullTime(closedAt)
+	status.FetchedAt, status.UsedAt = fromUnixNano(fetchedAt), fromUnixNano(usedAt)
+	return url, status, nil
+}
+
+func putPRStatus(db sqlExecer, url string, status PRStatus) error {
+	_, err := db.Exec(`INSERT OR REPLACE INTO pr_status (url, state, author, merged_at, closed_at, fetched_at, used_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		url, status.State, status.Author, nullUnixNano(status.MergedAt), nullUnixNano(status.ClosedAt),
+		unixNano(status.FetchedAt), unixNano(status.UsedAt))
+	return err
+}
+
+// scanIssueStatus scans a row of selectIssueStatus.
+func scanIssueStatus(row rowScanner) (string, IssueStatus, error) {
+	var url string
+	var status IssueStatus
+	var fetchedAt, usedAt int64
+	if err := row.Scan(&url, &status.Closed, &status.StateReason, &status.Author, &fetchedAt, &usedAt); err != nil {
+		return "", IssueStatus{}, err
+	}
+	status.FetchedAt, status.UsedAt = fromUnixNano(fetchedAt), fromUnixNano(usedAt)
+	return url, status, nil
+}
+
+func putIssueStatus(db sqlExecer, url string, status IssueStatus) error {
+	_, err := db.Exec(`INSERT OR REPLACE INTO issue_status (url, closed, state_reason, author, fetched_at, used_at)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		url, status.Closed, status.StateReason, status.Author, unixNano(status.FetchedAt), unixNano(status.UsedAt))
+	return err
+}
+
+func putClearedThread(db sqlExecer, id string, thread ClearedThread) error {
+	_, err := db.Exec("INSERT OR REPLACE INTO threads_deleted (id, updated_at, used_at) VALUES (?, ?, ?)",
+		id, unixNano(thread.UpdatedAt), unixNano(thread.UsedAt))
+	return err
+}
+
+func putUnsubscribed(db sqlExecer, id string) error {
+	_, err := db.Exec("INSERT OR IGNORE INTO threads_unsubscribed (id) VALUES (?)", id)
+	return err
+}
+
+// scanResponse scans a row of selectResponse.
+func scanResponse(row rowScanner) (string, CachedResponse, error) {
+	var key string
+	var response CachedResponse
+	var body []byte
+	var usedAt int64
+	if err := row.Scan(&key, &response.ETag, &response.LastModified, &response.Link, &body, &usedAt); err != nil {
+		return "", CachedResponse{}, err
+	}
+	if len(body) > 0 {
+		response.Body = json.RawMessage(body)
+	}
+	response.UsedAt = fromUnixNano(usedAt)
+	return key, response, nil
+}
+
+func putResponse(db sqlExecer, key string, response CachedResponse) error {
+	// The column is NOT NULL, so responses without a body store an empty one.
+	body := []byte(response.Body)
+	if body == nil {
+		body = []byte{}
+	}
+	_, err := db.Exec(`INSERT OR REPLACE INTO responses (key, etag, last_modified, link, body, used_at)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		key, response.ETag, response.LastModified, response.Link, body, unixNano(response.UsedAt))
+	return err
+}
+
+// Times are stored as Unix nanoseconds, with 0 for the zero time.
+func unixNano(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+func fromUnixNano(n int64) time.Time {
+	if n == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, n).UTC()
+}
+
+func nullUnixNano(t *time.Time) sql.NullInt64 {
+	if t == nil {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: t.UnixNano(), Valid: true}
+}
+
+func nullTime(n sql.NullInt64) *time.Time {
+	if !n.Valid {
+		return nil
+	}
+	t := fromUnixNano(n.Int64)
+	return &t
+}
@@ -0,0 +1,270 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func loadSQLiteCache(t *testing.T, homeDir string, opts ...CacheOption) CacheService {
+	t.Helper()
+	svc := NewSQLiteCacheService(homeDir, opts...)
+	if _, err := svc.Load(); err != nil {
+		t.Fatalf("Failed to load cache: %v", err)
+	}
+	t.Cleanup(func() { svc.Close() })
+	return svc
+}
+
+func TestSQLiteCacheService_Persists(t *testing.T) {
+	tmpDir := t.TempDir()
+	mergedAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	sweep := time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC)
+
+	svc := loadSQLiteCache(t, tmpDir)
+	svc.SetPRStatus("pr1", PRStatus{State: PRStateMerged, Author: "alice", MergedAt: &mergedAt})
+	svc.SetIssueStatus("issue1", IssueStatus{Closed: true, StateReason: "completed"})
+	svc.SetThreadDeleted("thread1", mergedAt)
+	svc.SetThreadUnsubscribed("thread1")
+	svc.SetResponse("key1", CachedResponse{ETag: `"a"`, Body: []byte(`[1]`)})
+	svc.SetLastSweep(sweep)
+	svc.SetLastSweep(sweep.Add(-time.Hour))
+	svc.SetMaxWindow(7 * day)
+	svc.SetMaxWindow(day)
+	if err := svc.Save(nil); err != nil {
+		t.Fatalf("Failed to save cache: %v", err)
+	}
+
+	svc = loadSQLiteCache(t, tmpDir)
+	status, ok := svc.GetPRStatus("pr1")
+	if !ok || status.State != PRStateMerged || status.Author != "alice" || !status.MergedAt.Equal(mergedAt) || status.ClosedAt != nil {
+		t.Errorf("Unexpected PR status %+v", status)
+	}
+	if issue, ok := svc.GetIssueStatus("issue1"); !ok || !issue.Closed || issue.StateReason != "completed" {
+		t.Errorf("Unexpected issue status %+v", issue)
+	}
+	if !svc.IsThreadDeleted("thread1", mergedAt) || !svc.IsThreadUnsubscribed("thread1") {
+		t.Error("Expected thread1 to be cleared and unsubscribed")
+	}
+	if response, ok := svc.GetResponse("key1"); !ok || response.ETag != `"a"` || string(response.Body) != `[1]` {
+		t.Errorf("Unexpected response %+v", response)
+	}
+	if got := svc.GetLastSweep(); !got.Equal(sweep) {
+		t.Errorf("Expected last sweep %v, got %v", sweep, got)
+	}
+	if got := svc.GetMaxWindow(); got != 7*day {
+		t.Errorf("Expected max window %v, got %v", 7*day, got)
+	}
+}
+
+func TestSQLiteCacheService_IsThreadDeleted(t *testing.T) {
+	svc := loadSQLiteCache(t, t.TempDir())
+	updatedAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	svc.SetThreadDeleted("thread1", updatedAt)
+
+	if !svc.IsThreadDeleted("thread1", updatedAt) {
+		t.Error("Expected thread1 to be deleted at the same updated_at")
+	}
+	if svc.IsThreadDeleted("thread1", updatedAt.Add(time.Second)) {
+		t.Error("Expected thread1 with new activity not to be deleted")
+	}
+	if svc.IsThreadDeleted("thread2", updatedAt) {
+		t.Error("Expected thread2 not to be deleted")
+	}
+}
+
+func TestSQLiteCacheService_Evict(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	svc := loadSQLiteCache(t, t.TempDir(), WithEviction(EvictionPolicy{MaxAge: 30 * day, MaxEntries: 3}))
+	ss := svc.(*sqliteCacheService)
+
+	at := func(ago time.Duration) { ss.now = func() time.Time { return now.Add(-ago) } }
+
+	at(40 * day)
+	svc.SetPRStatus("old", PRStatus{State: PRStateMerged})
+	svc.SetPRStatus("old-but-read", PRStatus{State: PRStateMerged})
+	svc.SetThreadDeleted("old-thread", now.Add(-41*day))
+	svc.SetThreadUnsubscribed("old-thread")
+	svc.SetResponse("old-response", CachedResponse{ETag: `"a"`})
+	for i, ago := range []time.Duration{4 * day, 3 * day, 2 * day, day} {
+		at(ago)
+		svc.SetPRStatus(fmt.Sprint("recent", i), PRStatus{State: PRStateMerged})
+	}
+	at(day)
+	svc.GetPRStatus("old-but-read")
+
+	ss.now = func() time.Time { return now }
+	if err := svc.Save(nil); err != nil {
+		t.Fatalf("Failed to save cache: %v", err)
+	}
+
+	entries, err := svc.Entries("")
+	if err != nil {
+		t.Fatalf("Failed to list entries: %v", err)
+	}
+	var kept []string
+	for _, e := range entries {
+		kept = append(kept, e.Kind+":"+e.Key)
+	}
+	if want := []string{"pr:old-but-read", "pr:recent2", "pr:recent3"}; !slices.Equal(kept, want) {
+		t.Errorf("Expected entries %v to be kept, got %v", want, kept)
+	}
+}
+
+func TestSQLiteCacheService_EvictOutsideMaxWindow(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	svc := loadSQLiteCache(t, t.TempDir(), WithEviction(EvictionPolicy{MaxAge: 30 * day}))
+	ss := svc.(*sqliteCacheService)
+
+	ss.now = func() time.Time { return now.Add(-10 * day) }
+	svc.SetPRStatus("pr", PRStatus{State: PRStateMerged})
+	svc.SetThreadDeleted("thread", now.Add(-10*day))
+
+	ss.now = func() time.Time { return now }
+	svc.SetMaxWindow(7 * day)
+	if err := svc.Save(nil); err != nil {
+		t.Fatalf("Failed to save cache: %v", err)
+	}
+
+	entries, err := svc.Entries("")
+	if err != nil {
+		t.Fatalf("Failed to list entries: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Kind != CacheKindThread {
+		t.Errorf("Expected only the cleared thread to outlive the window, got %v", entries)
+	}
+}
+
+func TestSQLiteCacheService_Maintenance(t *testing.T) {
+	svc := loadSQLiteCache(t, t.TempDir())
+	svc.SetPRStatus("https://api.github.com/repos/o/r/pulls/1", PRStatus{State: PRStateMerged})
+	svc.SetResponse("https://api.github.com/repos/o/r/pulls/1", CachedResponse{ETag: `"a"`})
+	svc.SetThreadDeleted("1", time.Now())
+
+	svc.GetPRStatus("https://api.github.com/repos/o/r/pulls/1")
+	svc.GetPRStatus("https://api.github.com/repos/o/r/pulls/2")
+	if err := svc.Save(nil); err != nil {
+		t.Fatalf("Failed to save cache: %v", err)
+	}
+	svc.GetPRStatus("https://api.github.com/repos/o/r/pulls/3")
+	svc.GetPRStatus("https://api.github.com/repos/o/r/pulls/4")
+
+	stats := svc.Stats()
+	if pr := stats.Kinds[CacheKindPR]; pr.Entries != 1 || pr.Hits != 1 || pr.Misses != 3 || pr.HitRate != 0.25 {
+		t.Errorf("Unexpected PR stats: %+v", pr)
+	}
+	if stats.Version != len(sqliteMigrations) {
+		t.Errorf("Expected schema version %d, got %d", len(sqliteMigrations), stats.Version)
+	}
+
+	n, err := svc.Forget("", "https://api.github.com/repos/o/r/pulls/1")
+	if err != nil || n != 2 {
+		t.Errorf("Expected to forget the PR status and response, got %d, %v", n, err)
+	}
+
+	var exported bytes.Buffer
+	if err := svc.Export(&exported); err != nil {
+		t.Fatalf("Failed to export cache: %v", err)
+	}
+	if err := svc.Clear(); err != nil {
+		t.Fatalf("Failed to clear cache: %v", err)
+	}
+	if entries, _ := svc.Entries(""); len(entries) != 0 {
+		t.Fatalf("Expected Clear to remove every entry, got %v", entries)
+	}
+	if err := svc.Import(&exported); err != nil {
+		t.Fatalf("Failed to import cache: %v", err)
+	}
+	entries, err := svc.Entries("")
+	if err != nil || len(entries) != 1 || entries[0].Kind != CacheKindThread {
+		t.Errorf("Expected the exported cache back, got %v, %v", entries, err)
+	}
+}
+
+func TestSQLiteCacheService_PruneError(t *testing.T) {
+	svc := loadSQLiteCache(t, t.TempDir(), WithEviction(EvictionPolicy{MaxAge: day}))
+	if _, err := svc.(*sqliteCacheService).db.Exec("DROP TABLE responses"); err != nil {
+		t.Fatalf("Failed to drop table: %v", err)
+	}
+
+	if _, err := svc.Prune(); err == nil {
+		t.Error("Expected Prune to return the error of the failed eviction")
+	}
+}
+
+func TestSQLiteCacheService_ImportsFileCache(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeTestCache(t, tmpDir, []byte(`{"pr_status":{"pr1":true},"threads_deleted":{"t1":true}}`))
+
+	svc := loadSQLiteCache(t, tmpDir)
+	if status, ok := svc.GetPRStatus("pr1"); !ok || status.State != PRStateMerged {
+		t.Errorf("Expected pr1 to be imported as merged, got %+v, %v", status, ok)
+	}
+	updatedAt := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	if !svc.IsThreadDeleted("t1", updatedAt) || svc.IsThreadReactivated("t1", updatedAt) {
+		t.Error("Expected t1 to be imported and to adopt the updated_at it is fetched with")
+	}
+	if !svc.IsThreadReactivated("t1", updatedAt.Add(time.Minute)) {
+		t.Error("Expected later activity to reactivate t1")
+	}
+
+	// The JSON cache is only imported into a new database.
+	writeTestCache(t, tmpDir, []byte(`{"pr_status":{"pr2":true}}`))
+	svc = loadSQLiteCache(t, tmpDir)
+	if _, ok := svc.GetPRStatus("pr2"); ok {
+		t.Error("Expected the JSON cache not to be imported again")
+	}
+}
+
+func TestSQLiteCacheService_LoadNewerSchema(t *testing.T) {
+	tmpDir := t.TempDir()
+	svc := loadSQLiteCache(t, tmpDir)
+	if _, err := svc.(*sqliteCacheService).db.Exec(fmt.Sprintf("PRAGMA user_version = %d", len(sqliteMigrations)+1)); err != nil {
+		t.Fatalf("Failed to set schema version: %v", err)
+	}
+
+	if _, err := NewSQLiteCacheService(tmpDir).Load(); !errors.Is(err, ErrCacheTooNew) {
+		t.Errorf("Expected ErrCacheTooNew, got %v", err)
+	}
+}
+
+func TestSQLiteCacheService_RunHistory(t *testing.T) {
+	tmpDir := t.TempDir()
+	history := loadSQLiteCache(t, tmpDir).(RunHistory)
+
+	start := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	for i := range 3 {
+		var summary Summary
+		summary.record("o/r", Counts{Fetched: i + 1, Cleared: i})
+		run := RunRecord{StartedAt: start.Add(time.Duration(i) * time.Hour), FinishedAt: start.Add(time.Duration(i)*time.Hour + time.Minute), Since: "1d", Summary: summary}
+		if err := history.RecordRun(run); err != nil {
+			t.Fatalf("Failed to record run: %v", err)
+		}
+	}
+
+	svc := loadSQLiteCache(t, tmpDir)
+	if err := svc.Clear(); err != nil {
+		t.Fatalf("Failed to clear cache: %v", err)
+	}
+	runs, err := svc.(RunHistory).Runs(2)
+	if err != nil {
+		t.Fatalf("Failed to read runs: %v", err)
+	}
+	if len(runs) != 2 {
+		t.Fatalf("Expected 2 runs, got %d", len(runs))
+	}
+	if !runs[0].StartedAt.Equal(start.Add(2*time.Hour)) || runs[0].Summary.Fetched != 3 || runs[0].Summary.Cleared != 2 {
+		t.Errorf("Expected the most recent run first, got %+v", runs[0])
+	}
+	if got := runs[1].Summary.Repositories["o/r"]; got != (Counts{Fetched: 2, Cleared: 1}) {
+		t.Errorf("Unexpected repository counts %+v", got)
+	}
+	if _, err := os.Stat(filepath.Join(CacheDir(tmpDir), "cache.db")); err != nil {
+		t.Errorf("Expected cache.db in the cache directory: %v", err)
+	}
+}
@@ -0,0 +1,80 @@
+package core
+
+import (
+	"errors"
+	"maps"
+	"slices"
+	"time"
+)
+
+// ErrClearFailed is returned by Apply when some notifications in the plan
+// could not be cleared.
+var ErrClearFailed = errors.New("some notifications could not be cleared")
+
+// ErrIncomplete is returned when a sweep did not evaluate every notification
+// in its window, because the fetch stopped at the page limit or the rules
+// could not be evaluated for some notifications.
+var ErrIncomplete = errors.New("not every notification was evaluated")
+
+// Counts tallies what happened to the notifications of a sweep.
+type Counts struct {
+	// Fetched notifications are every notification in the window.
+	Fetched int `json:"fetched"`
+	// Filtered notifications were excluded by the filter flags.
+	Filtered int `json:"filtered"`
+	// Skipped notifications were already cleared, according to the cache.
+	Skipped int `json:"skipped"`
+	// Checked notifications were evaluated against the rules.
+	Checked int `json:"checked"`
+	Cleared int `json:"cleared"`
+	// Failed notifications could not be evaluated or cleared.
+	Failed int `json:"failed"`
+}
+
+func (c *Counts) add(other Counts) {
+	c.Fetched += other.Fetched
+	c.Filtered += other.Filtered
+	c.Skipped += other.Skipped
+	c.Checked += other.Checked
+	c.Cleared += other.Cleared
+	c.Failed += other.Failed
+}
+
+// Summary reports a sweep in total and for each repository.
+type Summary struct {
+	Counts
+	Repositories map[string]Counts `json:"repositories,omitempty"`
+}
+
+// record adds counts for a repository to it and to the total.
+func (s *Summary) record(repo string, counts Counts) {
+	s.Counts.add(counts)
+	if s.Repositories == nil {
+		s.Repositories = make(map[string]Counts)
+	}
+	repoCounts := s.Repositories[repo]
+	repoCounts.add(counts)
+	s.Repositories[repo] = repoCounts
+}
+
+// RepositoryNames returns the repositories in the summary, sorted.
+func (s *Summary) RepositoryNames() []string {
+	return slices.Sorted(maps.Keys(s.Repositories))
+}
+
+// RunRecord is a sweep as kept in the run history.
+type RunRecord struct {
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	Since      string    `json:"since"`
+	Summary    Summary   `json:"summary"`
+	// Error is why the run failed or did not handle every notification.
+	Error string `json:"error,omitempty"`
+}
+
+// RunHistory is implemented by caches that keep a history of runs.
+type RunHistory interface {
+	RecordRun(run RunRecord) error
+	// Runs returns up to limit runs, most recent first.
+	Runs(limit int) ([]RunRecord, error)
+}